@@ -1,92 +1,129 @@
 package attract
 
 import (
+	"fmt"
+	"life/expr"
 	"life/settings"
 	"log"
+	"math"
 	"math/rand"
-	"time"
+	"unicode"
 )
 
 var AttractionMatrix = [][]float64{}
 var RadiusMatrix = [][]float64{}
 
-func RandomizeAttractionMatrix() {
-	switch settings.AttractionSelection {
-	case "random":
-		for j := 0; j < settings.MaxTypes; j++ {
-			for i := 0; i < settings.MaxTypes; i++ {
-				AttractionMatrix[i][j] = 2*settings.RandomFunc() - 1
-			}
-		}
-	case "cluster":
-		for j := 0; j < settings.MaxTypes; j++ {
-			for i := 0; i < settings.MaxTypes; i++ {
-				if i == j {
-					AttractionMatrix[i][j] = 1
-				} else {
-					AttractionMatrix[i][j] = 0
-				}
-			}
-		}
-	default:
-		log.Fatal("Invalid attraction type")
+// RandomizeAttractionMatrix fills AttractionMatrix with the generator named
+// by settings.AttractionSelection, drawing from r.
+func RandomizeAttractionMatrix(r *rand.Rand) error {
+	if err := CheckGenerator(settings.AttractionSelection); err != nil {
+		return err
 	}
+	Generators[settings.AttractionSelection](r, AttractionMatrix)
+	return nil
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-
-	for i := 0; i < settings.MaxTypes; i++ {
-		AttractionMatrix = append(AttractionMatrix, []float64{})
-		RadiusMatrix = append(RadiusMatrix, []float64{})
-		for j := 0; j < settings.MaxTypes; j++ {
-			AttractionMatrix[i] = append(AttractionMatrix[i], 0)
-			RadiusMatrix[i] = append(RadiusMatrix[i], 0)
+// RandomizeRadiusMatrix fills RadiusMatrix with interaction radii in
+// [settings.MinRadius, settings.MaxRadius] according to
+// settings.RadiiSelection, drawing from r.
+func RandomizeRadiusMatrix(r *rand.Rand) {
+	for j := range RadiusMatrix {
+		for i := range RadiusMatrix {
+			RadiusMatrix[i][j] = randomRadius(r)
 		}
 	}
+}
 
-	RandomizeAttractionMatrix()
+// CheckRadii returns an error unless settings.MinRadius and
+// settings.MaxRadius are positive, finite and in order.
+func CheckRadii() error {
+	switch {
+	case !(settings.MinRadius > 0) || math.IsInf(settings.MinRadius, 1):
+		return fmt.Errorf("min radius %v is not a finite, positive number", settings.MinRadius)
+	case !(settings.MaxRadius > 0) || math.IsInf(settings.MaxRadius, 1):
+		return fmt.Errorf("max radius %v is not a finite, positive number", settings.MaxRadius)
+	case settings.MinRadius > settings.MaxRadius:
+		return fmt.Errorf("min radius %v is more than max radius %v", settings.MinRadius, settings.MaxRadius)
+	}
+	return nil
+}
 
+func randomRadius(r *rand.Rand) float64 {
 	switch settings.RadiiSelection {
 	case "random":
-		for j := 0; j < settings.MaxTypes; j++ {
-			for i := 0; i < settings.MaxTypes; i++ {
-				RadiusMatrix[i][j] = 2*settings.RandomFunc() - 1
-			}
+		return settings.MinRadius + (settings.MaxRadius-settings.MinRadius)*settings.RandomFunc(r)
+	case "equal":
+		return settings.MinRadius
+	}
+	log.Fatal("Invalid radius type")
+	return 0
+}
+
+// Resize grows both matrices to hold at least n types. The new attraction
+// entries are drawn uniformly from [-1, 1] and the new radii as
+// RandomizeRadiusMatrix would. Existing entries are kept, and the matrices
+// never shrink, so types that are removed and added again keep their rules.
+func Resize(n int, r *rand.Rand) {
+	old := len(AttractionMatrix)
+	if n <= old {
+		return
+	}
+	for i := range AttractionMatrix {
+		for j := old; j < n; j++ {
+			AttractionMatrix[i] = append(AttractionMatrix[i], uniform(r))
+			RadiusMatrix[i] = append(RadiusMatrix[i], randomRadius(r))
+		}
+	}
+	for i := old; i < n; i++ {
+		AttractionMatrix = append(AttractionMatrix, make([]float64, n))
+		RadiusMatrix = append(RadiusMatrix, make([]float64, n))
+		for j := 0; j < n; j++ {
+			AttractionMatrix[i][j] = uniform(r)
+			RadiusMatrix[i][j] = randomRadius(r)
 		}
+	}
+}
 
-	case "equal":
-		for j := 0; j < settings.MaxTypes; j++ {
-			for i := 0; i < settings.MaxTypes; i++ {
-				RadiusMatrix[i][j] = settings.MinRadius
-			}
+// LargestRadius returns the largest interaction radius between the first n
+// types. No pair kernel has any effect beyond it.
+func LargestRadius(n int) float64 {
+	largest := 0.
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			largest = math.Max(largest, RadiusMatrix[i][j])
 		}
-	default:
-		log.Fatal("Invalid radius type")
 	}
+	return largest
 }
 
-type AttractionFunction func(float64, int8, int8) float64
+// AttractionFunction gives the force a particle of type t feels from one of
+// type ot at distance d. Every pair kernel is cut off at
+// RadiusMatrix[t][ot].
+type AttractionFunction func(float64, uint16, uint16) float64
 
 var HalfRepelRadius = settings.RepelRadius / 2
 
 func AbsoluteAttractionFunc() AttractionFunction {
-	return func(d float64, t, ot int8) float64 {
+	return func(d float64, t, ot uint16) float64 {
 		h := AttractionMatrix[t][ot]
-		k := RadiusMatrix[t][ot]
+		radius := RadiusMatrix[t][ot]
 
+		if d > radius {
+			return 0
+		}
 		if d < settings.RepelRadius {
 			return -(settings.RepelStrength / (d/settings.RepelRadius + 1)) + HalfRepelRadius
 		}
-		if d < settings.RepelRadius+2/k {
-			return -h*(k*d-k*settings.RepelRadius-1) + h
-		}
-		return 0
+		// falls linearly from 2h at the repel radius to 0 at the interaction radius
+		return 2 * h * (radius - d) / (radius - settings.RepelRadius)
 	}
 }
 
 func ClusterAttractionFunc() AttractionFunction {
-	return func(d float64, t int8, ot int8) float64 {
+	return func(d float64, t, ot uint16) float64 {
+		if d > RadiusMatrix[t][ot] {
+			return 0
+		}
 		if d < settings.RepelRadius {
 			return -settings.RepelStrength / (d)
 		}
@@ -99,7 +136,10 @@ func ClusterAttractionFunc() AttractionFunction {
 }
 
 func SnakeAttractionFunc() AttractionFunction {
-	return func(d float64, t int8, ot int8) float64 {
+	return func(d float64, t, ot uint16) float64 {
+		if d > RadiusMatrix[t][ot] {
+			return 0
+		}
 		if d < 10 {
 			return -1 / (d)
 		}
@@ -113,7 +153,10 @@ func SnakeAttractionFunc() AttractionFunction {
 }
 
 func DefaultAttractionFunc() AttractionFunction {
-	return func(d float64, t int8, ot int8) float64 {
+	return func(d float64, t, ot uint16) float64 {
+		if d > RadiusMatrix[t][ot] {
+			return 0
+		}
 		if d < settings.RepelRadius {
 			return -settings.RepelStrength / (d)
 		}
@@ -121,9 +164,13 @@ func DefaultAttractionFunc() AttractionFunction {
 	}
 }
 
-func SimpleAttractionFunc() AttractionFunction {
-	v := rand.NormFloat64()
-	return func(d float64, t int8, ot int8) float64 {
+// SimpleAttractionFunc attracts with strength v at every distance past the
+// repel radius.
+func SimpleAttractionFunc(v float64) AttractionFunction {
+	return func(d float64, t, ot uint16) float64 {
+		if d > RadiusMatrix[t][ot] {
+			return 0
+		}
 		if d < settings.RepelRadius {
 			return -settings.RepelStrength / (d)
 		}
@@ -131,9 +178,114 @@ func SimpleAttractionFunc() AttractionFunction {
 	}
 }
 
-func MouseAttraction(d float64, t, ot int8) float64 {
+// MouseAttraction is not a pair kernel, so it ignores the radius matrix.
+func MouseAttraction(d float64, t, ot uint16) float64 {
 	if d < 60 {
 		return -25 / d
 	}
 	return 0
 }
+
+// MouseAttractionFunc is MouseAttraction as a pair kernel, cut off at the
+// radius matrix like the others.
+func MouseAttractionFunc() AttractionFunction {
+	return func(d float64, t, ot uint16) float64 {
+		if d > RadiusMatrix[t][ot] {
+			return 0
+		}
+		return MouseAttraction(d, t, ot)
+	}
+}
+
+// Kernel builds the pair kernels of one kind. A pair using it has Params
+// parameters, drawn by Draw when the pair is set, and New builds its function
+// from them. Pairs keep their parameters, so saving them is enough to rebuild
+// a pair exactly.
+type Kernel struct {
+	Params int
+	Draw   func(r *rand.Rand) []float64
+	New    func(params []float64) AttractionFunction
+}
+
+// fixed is a Kernel without parameters.
+func fixed(f func() AttractionFunction) Kernel {
+	return Kernel{New: func([]float64) AttractionFunction { return f() }}
+}
+
+// Kernels holds each of the pair kernels by name.
+var Kernels = map[string]Kernel{
+	"default":  fixed(DefaultAttractionFunc),
+	"absolute": fixed(AbsoluteAttractionFunc),
+	"cluster":  fixed(ClusterAttractionFunc),
+	"snake":    fixed(SnakeAttractionFunc),
+	"simple": {
+		Params: 1,
+		Draw:   func(r *rand.Rand) []float64 { return []float64{r.NormFloat64()} },
+		New:    func(params []float64) AttractionFunction { return SimpleAttractionFunc(params[0]) },
+	},
+	"mouse": fixed(MouseAttractionFunc),
+	"curve": fixed(CurveAttractionFunc),
+}
+
+// KernelNames lists Kernels in a stable order, e.g. for cycling through them.
+var KernelNames = []string{"default", "absolute", "cluster", "snake", "simple", "mouse", "curve"}
+
+// ExpressionVars are the variables a kernel expression can use: the distance,
+// the repel radius and strength, the attraction and radius matrix entries for
+// the pair, and the two types.
+var ExpressionVars = []string{"d", "r", "s", "a", "k", "t", "ot"}
+
+// Expressions holds the source of every kernel added with RegisterExpression,
+// by name.
+var Expressions = map[string]string{}
+
+// ExpressionAttractionFunc turns a program compiled with ExpressionVars into a
+// kernel. Like the others, it is cut off at the radius matrix.
+func ExpressionAttractionFunc(p *expr.Program) AttractionFunction {
+	return func(d float64, t, ot uint16) float64 {
+		if d > RadiusMatrix[t][ot] {
+			return 0
+		}
+		vars := [expr.MaxVars]float64{
+			d, settings.RepelRadius, settings.RepelStrength,
+			AttractionMatrix[t][ot], RadiusMatrix[t][ot],
+			float64(t), float64(ot),
+		}
+		return p.Eval(&vars)
+	}
+}
+
+// identifier reports whether name is usable as a kernel name, which has to
+// survive the comma separated list of -kernel.
+func identifier(name string) bool {
+	for i, c := range name {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// RegisterExpression compiles src and adds it to Kernels under name, replacing
+// any expression already registered with that name.
+func RegisterExpression(name, src string) error {
+	if !identifier(name) {
+		return fmt.Errorf("kernel name %q is not an identifier", name)
+	}
+	if _, ok := Expressions[name]; !ok {
+		if _, ok := Kernels[name]; ok {
+			return fmt.Errorf("kernel %q is built in", name)
+		}
+	}
+	p, err := expr.Compile(src, ExpressionVars...)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := Expressions[name]; !ok {
+		KernelNames = append(KernelNames, name)
+	}
+	Expressions[name] = src
+	Kernels[name] = fixed(func() AttractionFunction { return ExpressionAttractionFunc(p) })
+	return nil
+}
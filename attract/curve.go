@@ -0,0 +1,84 @@
+package attract
+
+import "sort"
+
+// CurveRange is the largest force a curve point can have, either way.
+const CurveRange = 0.1
+
+// Curve is a force law drawn as control points of force against distance.
+// Between points it is interpolated linearly, or with a Catmull-Rom spline
+// when Smooth is set. Before the first point it keeps the first point's force
+// and beyond the last it is zero.
+type Curve struct {
+	// Points are distance, force pairs sorted by distance.
+	Points [][2]float64
+	Smooth bool
+}
+
+// DefaultCurve is a short range repulsion around a ring shaped well.
+func DefaultCurve() Curve {
+	return Curve{Points: [][2]float64{{0, -0.1}, {20, 0}, {50, 0.03}, {100, 0}, {150, -0.01}, {200, 0}}}
+}
+
+// ForceCurve is the curve used by the "curve" kernel.
+var ForceCurve = DefaultCurve()
+
+// Sort puts the points back in order of distance, e.g. after one is dragged
+// past another.
+func (c *Curve) Sort() {
+	sort.SliceStable(c.Points, func(i, j int) bool { return c.Points[i][0] < c.Points[j][0] })
+}
+
+// At returns the force at distance d.
+func (c *Curve) At(d float64) float64 {
+	ps := c.Points
+	if len(ps) == 0 || d > ps[len(ps)-1][0] {
+		return 0
+	}
+	if d <= ps[0][0] {
+		return ps[0][1]
+	}
+	i := sort.Search(len(ps), func(i int) bool { return ps[i][0] >= d }) - 1
+	a, b := ps[i], ps[i+1]
+	if b[0] == a[0] {
+		return b[1]
+	}
+	s := (d - a[0]) / (b[0] - a[0])
+	if !c.Smooth {
+		return a[1] + s*(b[1]-a[1])
+	}
+
+	// cubic Hermite with Catmull-Rom tangents, scaled to the uneven spacing
+	// of the points
+	m0 := c.tangent(i) * (b[0] - a[0])
+	m1 := c.tangent(i+1) * (b[0] - a[0])
+	s2, s3 := s*s, s*s*s
+	return (2*s3-3*s2+1)*a[1] + (s3-2*s2+s)*m0 + (-2*s3+3*s2)*b[1] + (s3-s2)*m1
+}
+
+// tangent is the slope at point i, from its neighbours.
+func (c *Curve) tangent(i int) float64 {
+	ps := c.Points
+	lo, hi := i-1, i+1
+	if lo < 0 {
+		lo = 0
+	}
+	if hi >= len(ps) {
+		hi = len(ps) - 1
+	}
+	if ps[hi][0] == ps[lo][0] {
+		return 0
+	}
+	return (ps[hi][1] - ps[lo][1]) / (ps[hi][0] - ps[lo][0])
+}
+
+// CurveAttractionFunc follows ForceCurve, so changes to the curve take effect
+// at once. Like the others, it is cut off at the radius matrix.
+func CurveAttractionFunc() AttractionFunction {
+	return func(d float64, t, ot uint16) float64 {
+		if d > RadiusMatrix[t][ot] {
+			return 0
+		}
+		return ForceCurve.At(d)
+	}
+}
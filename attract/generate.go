@@ -0,0 +1,202 @@
+package attract
+
+import (
+	"fmt"
+	"life/settings"
+	"math"
+	"math/rand"
+)
+
+// Generators fill a square attraction matrix, drawing from r. Those with a
+// structure that wraps around, like rock-paper-scissors, wrap at
+// settings.Types.
+var Generators = map[string]func(r *rand.Rand, m [][]float64){
+	"random":        randomMatrix,
+	"cluster":       clusterMatrix,
+	"symmetric":     symmetricMatrix,
+	"antisymmetric": antisymmetricMatrix,
+	"rps":           rpsMatrix,
+	"chain":         chainMatrix,
+	"banded":        bandedMatrix,
+	"sparse":        sparseMatrix,
+	"gaussian":      gaussianMatrix,
+	"charge":        chargeMatrix,
+}
+
+// GeneratorNames lists Generators in a stable order, e.g. for cycling through
+// them.
+var GeneratorNames = []string{"random", "cluster", "symmetric", "antisymmetric", "rps", "chain", "banded", "sparse", "gaussian", "charge"}
+
+// CheckGenerator returns an error if there is no generator called name, or a
+// setting the generators draw with is out of range.
+func CheckGenerator(name string) error {
+	if _, ok := Generators[name]; !ok {
+		return fmt.Errorf("unknown matrix generator %q", name)
+	}
+	return CheckGeneratorSettings()
+}
+
+// CheckGeneratorSettings returns an error if a setting the generators draw
+// with is out of range.
+func CheckGeneratorSettings() error {
+	switch {
+	case !(settings.MatrixDensity >= 0 && settings.MatrixDensity <= 1):
+		return fmt.Errorf("matrix density %v is not between 0 and 1", settings.MatrixDensity)
+	case settings.MatrixBand < 0:
+		return fmt.Errorf("matrix band %d is negative", settings.MatrixBand)
+	case !(settings.MatrixSigma >= 0) || math.IsInf(settings.MatrixSigma, 1):
+		return fmt.Errorf("matrix sigma %v is not a finite, non-negative number", settings.MatrixSigma)
+	case settings.MatrixRank < 1:
+		return fmt.Errorf("matrix rank %d is less than 1", settings.MatrixRank)
+	}
+	return nil
+}
+
+func uniform(r *rand.Rand) float64 {
+	return 2*settings.RandomFunc(r) - 1
+}
+
+func clampUnit(v float64) float64 {
+	return math.Max(-1, math.Min(v, 1))
+}
+
+// randomMatrix draws every entry uniformly from [-1, 1].
+func randomMatrix(r *rand.Rand, m [][]float64) {
+	for j := range m {
+		for i := range m {
+			m[i][j] = uniform(r)
+		}
+	}
+}
+
+// clusterMatrix only attracts types to themselves.
+func clusterMatrix(r *rand.Rand, m [][]float64) {
+	for j := range m {
+		for i := range m {
+			if i == j {
+				m[i][j] = 1
+			} else {
+				m[i][j] = 0
+			}
+		}
+	}
+}
+
+// symmetricMatrix makes every pair attract or repel each other equally.
+func symmetricMatrix(r *rand.Rand, m [][]float64) {
+	for i := range m {
+		for j := i; j < len(m); j++ {
+			m[i][j] = uniform(r)
+			m[j][i] = m[i][j]
+		}
+	}
+}
+
+// antisymmetricMatrix makes every type chase the types that flee from it.
+func antisymmetricMatrix(r *rand.Rand, m [][]float64) {
+	for i := range m {
+		m[i][i] = 0
+		for j := i + 1; j < len(m); j++ {
+			m[i][j] = uniform(r)
+			m[j][i] = -m[i][j]
+		}
+	}
+}
+
+// rpsMatrix is cyclic rock-paper-scissors: each type chases the next and
+// flees the one before, and clumps a little with its own kind.
+func rpsMatrix(r *rand.Rand, m [][]float64) {
+	n := settings.Types
+	for i := range m {
+		for j := range m {
+			switch (j - i%n + n) % n {
+			case 0:
+				m[i][j] = .5
+			case 1:
+				m[i][j] = 1
+			case n - 1:
+				m[i][j] = -1
+			default:
+				m[i][j] = 0
+			}
+		}
+	}
+}
+
+// chainMatrix is the matrix form of the snake kernel: each type is attracted
+// to itself and, more weakly, to the type before it, forming chains.
+func chainMatrix(r *rand.Rand, m [][]float64) {
+	for i := range m {
+		for j := range m {
+			switch j {
+			case i:
+				m[i][j] = 1
+			case i - 1:
+				m[i][j] = .5
+			default:
+				m[i][j] = 0
+			}
+		}
+	}
+}
+
+// bandedMatrix draws the entries within settings.MatrixBand of the diagonal
+// and leaves the rest at 0, so types only interact with near neighbours in
+// index.
+func bandedMatrix(r *rand.Rand, m [][]float64) {
+	for j := range m {
+		for i := range m {
+			if i-j <= settings.MatrixBand && j-i <= settings.MatrixBand {
+				m[i][j] = uniform(r)
+			} else {
+				m[i][j] = 0
+			}
+		}
+	}
+}
+
+// sparseMatrix draws each entry with probability settings.MatrixDensity and
+// leaves the rest at 0.
+func sparseMatrix(r *rand.Rand, m [][]float64) {
+	for j := range m {
+		for i := range m {
+			m[i][j] = 0
+			if settings.RandomFunc(r) < settings.MatrixDensity {
+				m[i][j] = uniform(r)
+			}
+		}
+	}
+}
+
+// gaussianMatrix draws every entry from a normal distribution with standard
+// deviation settings.MatrixSigma, clamped to [-1, 1].
+func gaussianMatrix(r *rand.Rand, m [][]float64) {
+	for j := range m {
+		for i := range m {
+			m[i][j] = clampUnit(r.NormFloat64() * settings.MatrixSigma)
+		}
+	}
+}
+
+// chargeMatrix gives each type settings.MatrixRank pairs of charges, one felt
+// and one exerted, and sets each entry to the sum of their products, giving a
+// matrix of that rank before clamping.
+func chargeMatrix(r *rand.Rand, m [][]float64) {
+	rank := settings.MatrixRank
+	felt, exerted := make([][]float64, len(m)), make([][]float64, len(m))
+	for i := range m {
+		felt[i], exerted[i] = make([]float64, rank), make([]float64, rank)
+		for k := 0; k < rank; k++ {
+			felt[i][k], exerted[i][k] = uniform(r), uniform(r)
+		}
+	}
+	for i := range m {
+		for j := range m {
+			sum := 0.
+			for k := 0; k < rank; k++ {
+				sum += felt[i][k] * exerted[j][k]
+			}
+			m[i][j] = clampUnit(sum / math.Sqrt(float64(rank)))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package attract
+
+import (
+	"life/settings"
+	"math/rand"
+)
+
+// The transformations act on the attraction matrix of the active types only,
+// keeping every entry in [-1, 1]. The radius matrix, the kernels and the
+// properties of each type are left as they are.
+
+// CopyMatrix returns a copy of the attraction matrix of the active types, e.g.
+// to undo a transformation with RestoreMatrix.
+func CopyMatrix() [][]float64 {
+	m := make([][]float64, settings.Types)
+	for i := range m {
+		m[i] = append([]float64(nil), AttractionMatrix[i][:settings.Types]...)
+	}
+	return m
+}
+
+// RestoreMatrix writes a matrix taken with CopyMatrix back.
+func RestoreMatrix(m [][]float64) {
+	for i := range m {
+		copy(AttractionMatrix[i], m[i])
+	}
+}
+
+// mapMatrix sets every entry to f of the entry and its transpose, as they
+// were before any were changed.
+func mapMatrix(f func(a, at float64) float64) {
+	old := CopyMatrix()
+	for i := range old {
+		for j := range old {
+			AttractionMatrix[i][j] = clampUnit(f(old[i][j], old[j][i]))
+		}
+	}
+}
+
+// Transpose swaps how each type feels the others with how they feel it.
+func Transpose() {
+	mapMatrix(func(a, at float64) float64 { return at })
+}
+
+// Symmetrize replaces each pair of entries with their mean.
+func Symmetrize() {
+	mapMatrix(func(a, at float64) float64 { return (a + at) / 2 })
+}
+
+// Antisymmetrize keeps only the part of each pair that differs, so that A→B
+// is the negative of B→A.
+func Antisymmetrize() {
+	mapMatrix(func(a, at float64) float64 { return (a - at) / 2 })
+}
+
+// Negate turns every attraction into repulsion and back.
+func Negate() {
+	mapMatrix(func(a, at float64) float64 { return -a })
+}
+
+// Scale multiplies every entry by f.
+func Scale(f float64) {
+	mapMatrix(func(a, at float64) float64 { return a * f })
+}
+
+// Mutate adds normally distributed noise with deviation sigma to every entry.
+func Mutate(r *rand.Rand, sigma float64) {
+	mapMatrix(func(a, at float64) float64 { return a + r.NormFloat64()*sigma })
+}
+
+// Permute relabels the types in the attraction matrix, so that type i
+// attracts and is attracted as type p[i] was.
+func Permute(p []int) {
+	old := CopyMatrix()
+	for i := range old {
+		for j := range old {
+			AttractionMatrix[i][j] = old[p[i]][p[j]]
+		}
+	}
+}
+
+// Rotate shifts the type indices of the attraction matrix by one, so each type
+// takes on the attractions of the type before it.
+func Rotate() {
+	p := make([]int, settings.Types)
+	for i := range p {
+		p[i] = (i + len(p) - 1) % len(p)
+	}
+	Permute(p)
+}
+
+// Shuffle relabels the types in the attraction matrix at random.
+func Shuffle(r *rand.Rand) {
+	Permute(r.Perm(settings.Types))
+}
+
+// Swap exchanges the attractions of types a and b.
+func Swap(a, b int) {
+	p := make([]int, settings.Types)
+	for i := range p {
+		p[i] = i
+	}
+	p[a], p[b] = b, a
+	Permute(p)
+}
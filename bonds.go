@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"life/settings"
+	"life/sim"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// The bonds page lists the bond rules and counts the bonds they formed.
+var bondsPage = newPage("Bonds", (*Game).drawBondPanel)
+
+// bondLines is how many rules the page lists, newest last.
+const bondLines = 4
+
+func init() {
+	p := bondsPage
+	p.button("Add", 8, pageY, 88, func(g *Game) {
+		g.prompt = &prompt{
+			help:   "Bond rule as A-B, with d=distance p=probability n=max bonds k=stiffness s=strain. Enter to add, Esc to cancel.",
+			submit: (*Game).submitBondRule,
+		}
+	})
+	p.button("Remove", 104, pageY, 88, func(g *Game) {
+		if n := len(g.world.BondRules); n > 0 {
+			g.world.BondRules = g.world.BondRules[:n-1]
+		}
+	})
+	p.button("Break All", 8, pageY+rowH+16*bondLines+22, 184, func(g *Game) {
+		g.world.Bonds = nil
+	})
+}
+
+func (g *Game) submitBondRule(text string) error {
+	r, err := sim.ParseBondRule(text)
+	if err != nil {
+		return err
+	}
+	return g.world.AddBondRule(r)
+}
+
+func (g *Game) drawBondPanel(screen *ebiten.Image) {
+	rules := g.world.BondRules
+	if len(rules) > bondLines {
+		rules = rules[len(rules)-bondLines:]
+	}
+	for n, r := range rules {
+		ebitenutil.DebugPrintAt(screen, r.String(), settings.Width+8, pageY+rowH+16*n)
+	}
+	if len(rules) == 0 {
+		ebitenutil.DebugPrintAt(screen, "No bond rules", settings.Width+8, pageY+rowH)
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Bonds: %d", len(g.world.Bonds)), settings.Width+8, pageY+rowH+16*bondLines+2)
+}
+
+// drawBonds draws each bond as a line between the centres of its particles in
+// the colour of the first, leaving out bonds that wrap around the world.
+func (g *Game) drawBonds(screen *ebiten.Image) {
+	ps := g.world.Particles
+	for _, b := range g.world.Bonds {
+		p, q := ps[b.I], ps[b.J]
+		if math.Abs(p.X-q.X) > settings.Width/2 || math.Abs(p.Y-q.Y) > settings.Height/2 {
+			continue
+		}
+		ph, qh := float64(g.world.Properties[p.Type].Size)/2, float64(g.world.Properties[q.Type].Size)/2
+		ebitenutil.DrawLine(screen, p.X+ph, p.Y+ph, q.X+qh, q.Y+qh, RGBColours[p.Type])
+	}
+}
@@ -0,0 +1,156 @@
+// Command headless runs a world without opening a window and writes the final
+// particle positions to stdout as CSV.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"life/sim"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	steps := flag.Int("steps", 1000, "number of ticks to simulate")
+	var reactions []sim.Reaction
+	var fields []sim.Field
+	var bonds []sim.BondRule
+	flag.IntVar(&settings.NParticles, "particles", settings.NParticles, "number of particles")
+	flag.IntVar(&settings.Types, "types", settings.Types, "number of particle types")
+	flag.StringVar(&settings.NeighbourSearch, "search", settings.NeighbourSearch, `neighbour search, "grid" or "all"`)
+	flag.StringVar(&settings.Metric, "metric", settings.Metric, `distance metric, "euclidean", "manhattan" or "chebyshev"`)
+	flag.StringVar(&settings.Boundary, "boundary", settings.Boundary, `edges of the world, "wrap", "reflect", "absorb", "cylinder" or "klein"`)
+	flag.StringVar(&settings.Integrator, "integrator", settings.Integrator, `integrator, "euler", "verlet" or "rk4"`)
+	flag.Float64Var(&settings.Temperature, "temperature", settings.Temperature, "temperature, which adds velocity noise")
+	flag.StringVar(&settings.Thermostat, "thermostat", settings.Thermostat, `thermostat, "none", "berendsen" or "langevin"`)
+	flag.Float64Var(&settings.ThermostatTau, "tau", settings.ThermostatTau, "time over which the thermostat reaches the temperature")
+	flag.StringVar(&settings.Collisions, "collisions", settings.Collisions, `collisions between particles, "none", "elastic" or "inelastic"`)
+	flag.Float64Var(&settings.CollisionRestitution, "collision-restitution", settings.CollisionRestitution, "speed kept by inelastic collisions")
+	flag.Float64Var(&settings.DT, "dt", settings.DT, "simulated time per step")
+	flag.Float64Var(&settings.MinRadius, "min-radius", settings.MinRadius, "smallest interaction radius")
+	flag.Float64Var(&settings.MaxRadius, "max-radius", settings.MaxRadius, "largest interaction radius")
+	flag.Func("matrix", "attraction matrix generator, one of "+strings.Join(attract.GeneratorNames, ", ")+` (default "`+settings.AttractionSelection+`")`, func(v string) error {
+		settings.AttractionSelection = v
+		return attract.CheckGenerator(v)
+	})
+	flag.Float64Var(&settings.MatrixDensity, "density", settings.MatrixDensity, `fraction of entries set by the "sparse" generator`)
+	flag.IntVar(&settings.MatrixBand, "band", settings.MatrixBand, `entries either side of the diagonal set by the "banded" generator`)
+	flag.Float64Var(&settings.MatrixSigma, "sigma", settings.MatrixSigma, `standard deviation of the "gaussian" generator`)
+	flag.IntVar(&settings.MatrixRank, "rank", settings.MatrixRank, `rank of the "charge" generator`)
+	flag.StringVar(&settings.Kernel, "kernel", settings.Kernel, "pair kernel for every type, or a comma separated list for types 0, 1, 2, ...")
+	flag.Func("expr", "define a kernel as name=expression over "+strings.Join(attract.ExpressionVars, ", ")+", usable with -kernel", func(v string) error {
+		name, src, ok := strings.Cut(v, "=")
+		if !ok {
+			return errors.New("want name=expression")
+		}
+		return attract.RegisterExpression(strings.TrimSpace(name), src)
+	})
+	flag.Func("reaction", "add a reaction rule such as 0+1>2 r=20 p=0.1, or 0+3*1>1 for 3 of type 1; repeatable", func(v string) error {
+		r, err := sim.ParseReaction(v)
+		reactions = append(reactions, r)
+		return err
+	})
+	flag.Func("field", "add a field such as gravity s=0.05 a=90 or well x=600 y=400 s=-1 r=50, one of "+strings.Join(sim.FieldNames, ", ")+"; repeatable", func(v string) error {
+		f, err := sim.ParseField(v)
+		fields = append(fields, f)
+		return err
+	})
+	flag.Func("bond", "add a bond rule such as 0-1 d=20 p=0.1 n=2 k=0.05 s=1; repeatable", func(v string) error {
+		r, err := sim.ParseBondRule(v)
+		bonds = append(bonds, r)
+		return err
+	})
+	flag.Int64Var(&settings.Seed, "seed", settings.Seed, "random seed, 0 picks one from the clock")
+	rules := flag.String("rules", "", "ruleset file to load")
+	obstacles := flag.String("obstacles", "", "JSON file with a list of obstacles to add")
+	mask := flag.String("mask", "", "PNG file whose dark pixels are solid, stretched over the world")
+	snapshot := flag.String("snapshot", "", "snapshot to start from, written with F6")
+	save := flag.String("save", "", "write a snapshot of the final state here")
+	flag.Parse()
+	if err := attract.CheckGeneratorSettings(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := attract.CheckRadii(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := sim.CheckThermostat(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := sim.CheckTimestep(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := sim.CheckCollisions(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	world := sim.NewWorld(settings.Seed)
+	fmt.Fprintf(os.Stderr, "seed: %d\n", world.Seed)
+	if *rules != "" {
+		if err := world.LoadRuleset(*rules); err != nil {
+			log.Fatal(err)
+		}
+		world.Setup()
+	}
+	if *obstacles != "" {
+		if err := world.LoadObstacles(*obstacles); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *mask != "" {
+		m, err := sim.LoadMask(*mask)
+		if err != nil {
+			log.Fatal(err)
+		}
+		world.Mask = m
+	}
+	if *obstacles != "" || *mask != "" {
+		world.Setup()
+	}
+	if *snapshot != "" {
+		if err := world.LoadSnapshot(*snapshot); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, r := range reactions {
+		if err := world.AddReaction(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, f := range fields {
+		if err := world.AddField(f); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, r := range bonds {
+		if err := world.AddBondRule(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for i := 0; i < *steps; i++ {
+		world.Step()
+	}
+	if *save != "" {
+		if err := world.SaveSnapshot(*save); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Println("x,y,vx,vy,type")
+	for _, p := range world.Particles {
+		fmt.Printf("%f,%f,%f,%f,%d\n", p.X, p.Y, p.Velocity[0], p.Velocity[1], p.Type)
+	}
+}
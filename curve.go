@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"life/attract"
+	"life/settings"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// The curve page shows attract.ForceCurve as a graph of force against
+// distance, from 0 to settings.MaxRadius across and attract.CurveRange up and
+// down. Dragging a point moves it, clicking elsewhere adds one and right
+// clicking removes one.
+const (
+	graphX = settings.Width + 8
+	graphY = pageY
+	graphW = settings.UIWidth - 16
+	graphH = 100
+)
+
+// grabDistance is how close to a point, in pixels, a click has to be to take
+// hold of it.
+const grabDistance = 6
+
+var curvePage = newPage("Curve", (*Game).drawCurve)
+
+func init() {
+	p := curvePage
+	p.ui[[4]int{graphX, graphY, graphW, graphH}] = [2]func(*Game){
+		(*Game).dragCurve,
+		func(g *Game) {
+			g.curvePoint = -1
+		},
+	}
+	p.button("Smooth", 8, graphY+graphH+6, 88, func(g *Game) {
+		attract.ForceCurve.Smooth = !attract.ForceCurve.Smooth
+	})
+	p.button("Reset", 104, graphY+graphH+6, 88, func(g *Game) {
+		attract.ForceCurve = attract.DefaultCurve()
+	})
+}
+
+func toGraph(pt [2]float64) (float64, float64) {
+	return graphX + pt[0]/settings.MaxRadius*graphW, graphY + graphH/2 - pt[1]/attract.CurveRange*graphH/2
+}
+
+func fromGraph(x, y int) [2]float64 {
+	d := math.Max(0, math.Min(float64(x-graphX)/graphW*settings.MaxRadius, settings.MaxRadius))
+	f := math.Max(-attract.CurveRange, math.Min(-float64(y-graphY-graphH/2)/(graphH/2)*attract.CurveRange, attract.CurveRange))
+	return [2]float64{d, f}
+}
+
+// nearestPoint returns the curve point within grabDistance of x, y, or -1.
+func nearestPoint(x, y int) int {
+	nearest, best := -1, float64(grabDistance)
+	for i, pt := range attract.ForceCurve.Points {
+		px, py := toGraph(pt)
+		if d := math.Hypot(px-float64(x), py-float64(y)); d <= best {
+			nearest, best = i, d
+		}
+	}
+	return nearest
+}
+
+// dragCurve takes hold of the point under the cursor, or a new one, and moves
+// it with the cursor while the button is held. A point cannot be dragged past
+// its neighbours, so the points stay sorted.
+func (g *Game) dragCurve() {
+	x, y := ebiten.CursorPosition()
+	c := &attract.ForceCurve
+	pt := fromGraph(x, y)
+	if g.curvePoint < 0 {
+		if g.curvePoint = nearestPoint(x, y); g.curvePoint < 0 {
+			c.Points = append(c.Points, pt)
+			c.Sort()
+			g.curvePoint = nearestPoint(x, y)
+		}
+		return
+	}
+
+	i := g.curvePoint
+	if i > 0 {
+		pt[0] = math.Max(pt[0], c.Points[i-1][0])
+	}
+	if i < len(c.Points)-1 {
+		pt[0] = math.Min(pt[0], c.Points[i+1][0])
+	}
+	c.Points[i] = pt
+}
+
+func (g *Game) drawCurve(screen *ebiten.Image) {
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		x, y := ebiten.CursorPosition()
+		if i := nearestPoint(x, y); i >= 0 && len(attract.ForceCurve.Points) > 1 {
+			attract.ForceCurve.Points = append(attract.ForceCurve.Points[:i], attract.ForceCurve.Points[i+1:]...)
+			// keep hold of the point being dragged, unless it was the one removed
+			if i == g.curvePoint {
+				g.curvePoint = -1
+			} else if i < g.curvePoint {
+				g.curvePoint--
+			}
+		}
+	}
+
+	axis := color.RGBA{150, 150, 150, 255}
+	ebitenutil.DrawLine(screen, graphX, graphY+graphH/2, graphX+graphW, graphY+graphH/2, axis)
+	repel := graphX + settings.RepelRadius/settings.MaxRadius*graphW
+	ebitenutil.DrawLine(screen, repel, graphY, repel, graphY+graphH, axis)
+
+	// the curve itself, one segment per pixel across
+	line := color.RGBA{255, 220, 0, 255}
+	lx, ly := toGraph([2]float64{0, attract.ForceCurve.At(0)})
+	for x := 1; x <= graphW; x++ {
+		d := float64(x) / graphW * settings.MaxRadius
+		px, py := toGraph([2]float64{d, math.Max(-attract.CurveRange, math.Min(attract.ForceCurve.At(d), attract.CurveRange))})
+		ebitenutil.DrawLine(screen, lx, ly, px, py, line)
+		lx, ly = px, py
+	}
+	for i, pt := range attract.ForceCurve.Points {
+		px, py := toGraph(pt)
+		c := color.RGBA{255, 255, 255, 255}
+		if i == g.curvePoint {
+			c = color.RGBA{255, 80, 80, 255}
+		}
+		ebitenutil.DrawRect(screen, px-2, py-2, 5, 5, c)
+	}
+
+	interp := "Linear"
+	if attract.ForceCurve.Smooth {
+		interp = "Catmull-Rom"
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%s, %d points", interp, len(attract.ForceCurve.Points)), settings.Width+8, graphY+graphH+30)
+	if g.curvePoint >= 0 {
+		pt := attract.ForceCurve.Points[g.curvePoint]
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("d: %.0f  f: %.3f", pt[0], pt[1]), settings.Width+8, graphY+graphH+48)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"life/attract"
+	"life/settings"
+	"math"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// The matrix editor shows the attraction and radius matrices side by side,
+// sharing the coloured type headers on the left.
+const (
+	editorY     = 328
+	editorWidth = (settings.UIWidth - 34) / 2
+)
+
+var editorX = [2]int{settings.Width + 22, settings.Width + 28 + editorWidth}
+
+func attractionColour(v float64) color.RGBA {
+	if v > 0 {
+		return color.RGBA{0, uint8(255 * v), 0, 255}
+	} else if v < 0 {
+		return color.RGBA{uint8(255 * -v), 0, 0, 255}
+	}
+	return color.RGBA{20, 20, 20, 255}
+}
+
+func radiusColour(v float64) color.RGBA {
+	f := 1.
+	if settings.MaxRadius > settings.MinRadius {
+		f = math.Max(0, math.Min(1, (v-settings.MinRadius)/(settings.MaxRadius-settings.MinRadius)))
+	}
+	return color.RGBA{20, 20, uint8(55 + 200*f), 255}
+}
+
+// nudgeSelected changes the selected cell of the active matrix by steps
+// increments, keeping it within range.
+func (g *Game) nudgeSelected(steps float64) {
+	i, j := g.matrixEditorLoc[0], g.matrixEditorLoc[1]
+	if g.editRadius {
+		attract.RadiusMatrix[i][j] = math.Max(settings.MinRadius, math.Min(settings.MaxRadius, attract.RadiusMatrix[i][j]+5*steps))
+	} else {
+		attract.AttractionMatrix[i][j] = math.Max(-1, math.Min(1, attract.AttractionMatrix[i][j]+0.1*steps))
+	}
+}
+
+// editorView scrolls the editor so the selection is in view, and returns the
+// first type shown on each axis and the number of types shown. Each cell is at
+// least a pixel wide, so with many types the editor never shows them all.
+func (g *Game) editorView() ([2]int, int) {
+	cells := settings.Types
+	if g.editorCells > 0 && g.editorCells < cells {
+		cells = g.editorCells
+	}
+	if cells > editorWidth {
+		cells = editorWidth
+	}
+	for k, loc := range g.matrixEditorLoc {
+		s := &g.editorScroll[k]
+		if loc < *s {
+			*s = loc
+		} else if loc >= *s+cells {
+			*s = loc - cells + 1
+		}
+		*s = int(math.Max(0, math.Min(float64(settings.Types-cells), float64(*s))))
+	}
+	return g.editorScroll, cells
+}
+
+// zoomEditor halves the number of types the editor shows for each step in,
+// or doubles it for each step out, until it shows them all again.
+func (g *Game) zoomEditor(in int) {
+	_, cells := g.editorView()
+	for ; in > 0; in-- {
+		cells = int(math.Max(2, float64(cells/2)))
+	}
+	for ; in < 0; in++ {
+		cells *= 2
+	}
+	g.editorCells = cells
+	if cells >= settings.Types {
+		g.editorCells = 0
+	}
+}
+
+// editorCell returns the matrix and cell under x, y, if any.
+func (g *Game) editorCell(x, y int) (m, i, j int, ok bool) {
+	first, cells := g.editorView()
+	boxWidth := editorWidth / cells
+	for m = range editorX {
+		if x >= editorX[m] && x < editorX[m]+cells*boxWidth && y >= editorY && y < editorY+cells*boxWidth {
+			return m, first[0] + (x-editorX[m])/boxWidth, first[1] + (y-editorY)/boxWidth, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// updateEditor lets the mouse pick a cell by clicking it and zoom with the
+// wheel over either grid.
+func (g *Game) updateEditor() {
+	x, y := ebiten.CursorPosition()
+	m, i, j, ok := g.editorCell(x, y)
+	if !ok {
+		return
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		g.matrixEditorLoc = [2]int{i, j}
+		g.editRadius = m == 1
+	}
+	if _, dy := ebiten.Wheel(); dy > 0 {
+		g.zoomEditor(1)
+	} else if dy < 0 {
+		g.zoomEditor(-1)
+	}
+}
+
+// kernelLabel returns the shortest start of name that no other kernel in
+// attract.KernelNames starts with, e.g. "cl" for cluster and "cu" for curve.
+func kernelLabel(name string) string {
+	for n := 1; n < len(name); n++ {
+		unique := true
+		for _, other := range attract.KernelNames {
+			if other != name && strings.HasPrefix(other, name[:n]) {
+				unique = false
+				break
+			}
+		}
+		if unique {
+			return name[:n]
+		}
+	}
+	return name
+}
+
+func (g *Game) drawEditor(screen *ebiten.Image) {
+	first, cells := g.editorView()
+	boxWidth := editorWidth / cells
+	matrices := [2][][]float64{attract.AttractionMatrix, attract.RadiusMatrix}
+	colours := [2]func(float64) color.RGBA{attractionColour, radiusColour}
+
+	for n := 0; n < cells; n++ {
+		// coloured headers
+		ebitenutil.DrawRect(
+			screen,
+			float64(settings.Width+8), float64(editorY+(n*boxWidth)),
+			10, float64(boxWidth),
+			RGBColours[first[1]+n],
+		)
+		i := first[0] + n
+		for m := range matrices {
+			ebitenutil.DrawRect(
+				screen,
+				float64(editorX[m]+(n*boxWidth)), float64(editorY-14),
+				float64(boxWidth), 10,
+				RGBColours[i],
+			)
+			for k := 0; k < cells; k++ {
+				j := first[1] + k
+				ebitenutil.DrawRect(
+					screen,
+					float64(editorX[m]+(n*boxWidth)), float64(editorY+(k*boxWidth)),
+					float64(boxWidth), float64(boxWidth),
+					colours[m](matrices[m][i][j]),
+				)
+				// short name of the kernel the pair uses, if there is room
+				if m == 0 && boxWidth >= 16 {
+					if label := kernelLabel(g.world.Kernels[i][j]); 6*len(label) <= boxWidth-2 {
+						ebitenutil.DebugPrintAt(
+							screen,
+							label,
+							editorX[m]+(n*boxWidth)+boxWidth/2-3*len(label),
+							editorY+(k*boxWidth)+boxWidth/2-8,
+						)
+					}
+				}
+			}
+		}
+	}
+
+	// add small white border to editor selection (editorLoc)
+	active := 0
+	if g.editRadius {
+		active = 1
+	}
+	ebitenutil.DrawRect(
+		screen,
+		float64(editorX[active]+((g.matrixEditorLoc[0]-first[0])*boxWidth)),
+		float64(editorY+((g.matrixEditorLoc[1]-first[1])*boxWidth)),
+		float64(math.Max(float64(boxWidth), 2)), 4,
+		color.RGBA{255, 255, 255, 255},
+	)
+
+	i, j := g.matrixEditorLoc[0], g.matrixEditorLoc[1]
+	readout := fmt.Sprintf("%d>%d A: %.1f R: %.0f\nKernel: %s", i, j, attract.AttractionMatrix[i][j], attract.RadiusMatrix[i][j], g.world.Kernels[i][j])
+	if cells < settings.Types {
+		readout += fmt.Sprintf("\nShowing %d-%d, %d-%d", first[0], first[0]+cells-1, first[1], first[1]+cells-1)
+	}
+	ebitenutil.DebugPrintAt(screen, readout, settings.Width+8, editorY+editorWidth+4)
+}
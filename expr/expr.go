@@ -0,0 +1,224 @@
+// Package expr compiles small arithmetic expressions, such as user defined
+// force laws, into programs that can be evaluated quickly and safely in the
+// inner loop of the simulation. Expressions have no loops, assignments or side
+// effects, so evaluation always terminates.
+//
+// The language has numbers, the variables given to Compile, the operators
+//
+//	?:  ||  &&  < <= > >= == !=  + -  * / %  ^  unary - + !
+//
+// from lowest to highest precedence, parentheses, and the functions abs, sqrt,
+// exp, log, sin, cos, tan, floor, ceil, min, max, pow and clamp. Comparisons
+// and logical operators give 1 for true and 0 for false.
+package expr
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxVars is the most variables a program can use.
+const MaxVars = 8
+
+// maxStack bounds how deeply an expression can nest.
+const maxStack = 64
+
+// Program is a compiled expression.
+type Program struct {
+	Source string
+	code   []instr
+}
+
+type opcode uint8
+
+const (
+	opConst opcode = iota
+	opVar
+	opNeg
+	opNot
+	opAdd
+	opSub
+	opMul
+	opDiv
+	opMod
+	opPow
+	opLess
+	opLessEq
+	opGreater
+	opGreaterEq
+	opEqual
+	opNotEqual
+	opCall
+	opJumpIfFalse // pops the condition
+	opJumpIfTrue  // leaves the condition for || to use as its result
+	opJumpIfZero  // leaves the condition for && to use as its result
+	opJump
+	opBool
+)
+
+type instr struct {
+	op  opcode
+	arg int
+	val float64
+}
+
+type function struct {
+	name string
+	args int
+	fn   func(a []float64) float64
+}
+
+// functions are called by their index, the arg of opCall, so Eval does not
+// look up names
+var functions = []function{
+	{"abs", 1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	{"sqrt", 1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	{"exp", 1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	{"log", 1, func(a []float64) float64 { return math.Log(a[0]) }},
+	{"sin", 1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	{"cos", 1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	{"tan", 1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	{"floor", 1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	{"ceil", 1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	{"min", 2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	{"max", 2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	{"pow", 2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	{"clamp", 3, func(a []float64) float64 { return math.Max(a[1], math.Min(a[2], a[0])) }},
+}
+
+// Compile parses src. The variables it may use are named by vars, and are
+// passed to Eval in the same order.
+func Compile(src string, vars ...string) (*Program, error) {
+	if len(vars) > MaxVars {
+		return nil, fmt.Errorf("expr: %d variables, at most %d allowed", len(vars), MaxVars)
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, vars: vars}
+	tree, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	prog := &Program{Source: src}
+	prog.emit(fold(tree))
+	if depth := prog.depth(); depth > maxStack {
+		return nil, fmt.Errorf("expr: expression nests too deeply")
+	}
+	return prog, nil
+}
+
+// Eval runs the program with the variables in the order given to Compile.
+func (p *Program) Eval(vars *[MaxVars]float64) float64 {
+	var stack [maxStack]float64
+	sp := 0
+	for pc := 0; pc < len(p.code); pc++ {
+		in := p.code[pc]
+		switch in.op {
+		case opConst:
+			stack[sp] = in.val
+			sp++
+		case opVar:
+			stack[sp] = vars[in.arg]
+			sp++
+		case opNeg:
+			stack[sp-1] = -stack[sp-1]
+		case opNot:
+			stack[sp-1] = truth(stack[sp-1] == 0)
+		case opBool:
+			stack[sp-1] = truth(stack[sp-1] != 0)
+		case opCall:
+			f := functions[in.arg]
+			sp -= f.args
+			stack[sp] = f.fn(stack[sp : sp+f.args])
+			sp++
+		case opJumpIfFalse:
+			sp--
+			if stack[sp] == 0 {
+				pc = in.arg - 1
+			}
+		case opJumpIfTrue:
+			if stack[sp-1] != 0 {
+				stack[sp-1] = 1
+				pc = in.arg - 1
+			} else {
+				sp--
+			}
+		case opJumpIfZero:
+			if stack[sp-1] == 0 {
+				pc = in.arg - 1
+			} else {
+				sp--
+			}
+		case opJump:
+			pc = in.arg - 1
+		default:
+			sp--
+			stack[sp-1] = binary(in.op, stack[sp-1], stack[sp])
+		}
+	}
+	return stack[0]
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func binary(op opcode, a, b float64) float64 {
+	switch op {
+	case opAdd:
+		return a + b
+	case opSub:
+		return a - b
+	case opMul:
+		return a * b
+	case opDiv:
+		return a / b
+	case opMod:
+		return math.Mod(a, b)
+	case opPow:
+		return math.Pow(a, b)
+	case opLess:
+		return truth(a < b)
+	case opLessEq:
+		return truth(a <= b)
+	case opGreater:
+		return truth(a > b)
+	case opGreaterEq:
+		return truth(a >= b)
+	case opEqual:
+		return truth(a == b)
+	case opNotEqual:
+		return truth(a != b)
+	}
+	panic("expr: unknown operator")
+}
+
+// depth returns the largest stack the program can need.
+func (p *Program) depth() int {
+	// jumps only skip forward over code that leaves the stack as it found
+	// it, so a straight pass gives an upper bound
+	sp, most := 0, 0
+	for _, in := range p.code {
+		switch in.op {
+		case opConst, opVar:
+			sp++
+		case opCall:
+			sp -= functions[in.arg].args - 1
+		case opJumpIfFalse:
+			sp--
+		case opNeg, opNot, opBool, opJump, opJumpIfTrue, opJumpIfZero:
+		default:
+			sp--
+		}
+		if sp > most {
+			most = sp
+		}
+	}
+	return most
+}
@@ -0,0 +1,98 @@
+package expr
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := [MaxVars]float64{2, 3, 5} // d, t, ot
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		// precedence
+		{"1+2*3", 7},
+		{"(1+2)*3", 9},
+		{"7-2-1", 4},
+		{"8/4/2", 1},
+		{"7%4*2", 6},
+		{"2^3^2", 512},
+		{"2*3^2", 18},
+		{"1+1<3", 1},
+		{"1<2==1", 1},
+		{"0||1&&0", 0},
+		{"1||0&&0", 1},
+		{"2||0", 1},
+		{"0?1:0?2:3", 3},
+		{"1?2:3+4", 2},
+
+		// unary minus
+		{"-2^2", -4},
+		{"2^-1", 0.5},
+		{"--3", 3},
+		{"2*-3", -6},
+		{"-d", -2},
+		{"+4", 4},
+		{"!0", 1},
+		{"!3", 0},
+
+		// functions
+		{"abs(-3)", 3},
+		{"sqrt(16)", 4},
+		{"exp(0)", 1},
+		{"log(e)", 1},
+		{"cos(pi)", -1},
+		{"floor(2.5)+ceil(2.5)", 5},
+		{"min(4, 2)", 2},
+		{"max(4, 2)", 4},
+		{"pow(2, 10)", 1024},
+		{"clamp(5, 0, 1)", 1},
+		{"clamp(-5, 0, 1)", 0},
+		{"max(min(d, t), ot/2)", 2.5},
+
+		// variables
+		{"d", 2},
+		{"d*t+ot", 11},
+		{"t==3 ? d : ot", 2},
+		{"d^t", 8},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.src, "d", "t", "ot")
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if got := p.Eval(&vars); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"", "unexpected end"},
+		{"(1+2", "unexpected end"},
+		{"1+2)", `unexpected ")"`},
+		{"((d)", "unexpected end"},
+		{"1+", "unexpected end"},
+		{"1 2", `unexpected "2"`},
+		{"x", `unknown variable "x"`},
+		{"d+dist", `unknown variable "dist"`},
+		{"foo(1)", `unknown function "foo"`},
+		{"min(1)", "min takes 2 arguments, got 1"},
+		{"abs(1, 2)", "abs takes 1 arguments, got 2"},
+		{"1 ? 2", "unexpected end"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src, "d", "t", "ot")
+		if err == nil {
+			t.Errorf("%q compiled, want an error containing %q", tt.src, tt.err)
+		} else if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: got error %q, want one containing %q", tt.src, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type token struct {
+	kind string // "num", "ident", "end", or the operator itself
+	text string
+	num  float64
+	pos  int
+}
+
+var operators = []string{"||", "&&", "<=", ">=", "==", "!=", "<", ">", "+", "-", "*", "/", "%", "^", "!", "?", ":", "(", ")", ","}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			// exponent, e.g. 1e-3
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				k := j + 1
+				if k < len(src) && (src[k] == '+' || src[k] == '-') {
+					k++
+				}
+				if k < len(src) && unicode.IsDigit(rune(src[k])) {
+					for j = k; j < len(src) && unicode.IsDigit(rune(src[j])); j++ {
+					}
+				}
+			}
+			v, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("expr: bad number %q at %d", src[i:j], i)
+			}
+			tokens = append(tokens, token{kind: "num", text: src[i:j], num: v, pos: i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{kind: "ident", text: src[i:j], pos: i})
+			i = j
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: op, text: op, pos: i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("expr: unexpected %q at %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: "end", pos: len(src)}), nil
+}
+
+// node is a parsed expression. Leaves are constants or variables; everything
+// else applies op to args.
+type node struct {
+	op   opcode
+	arg  int // variable or function index
+	val  float64
+	args []*node
+	// logical operators and ?: are not plain opcodes
+	logic string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	vars   []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != "end" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind string) error {
+	if t := p.next(); t.kind != kind {
+		return p.unexpected(t)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == "end" {
+		return fmt.Errorf("expr: unexpected end of expression")
+	}
+	return fmt.Errorf("expr: unexpected %q at %d", t.text, t.pos)
+}
+
+func (p *parser) parse() (*node, error) {
+	n, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "end" {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) ternary() (*node, error) {
+	cond, err := p.logical(0)
+	if err != nil || p.peek().kind != "?" {
+		return cond, err
+	}
+	p.next()
+	a, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	b, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return &node{logic: "?", args: []*node{cond, a, b}}, nil
+}
+
+// binary operators by precedence, lowest first
+var levels = [][]string{
+	{"||"},
+	{"&&"},
+	{"<", "<=", ">", ">=", "==", "!="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+var binaryOps = map[string]opcode{
+	"<": opLess, "<=": opLessEq, ">": opGreater, ">=": opGreaterEq, "==": opEqual, "!=": opNotEqual,
+	"+": opAdd, "-": opSub, "*": opMul, "/": opDiv, "%": opMod, "^": opPow,
+}
+
+func (p *parser) logical(level int) (*node, error) {
+	if level == len(levels) {
+		return p.unary()
+	}
+	left, err := p.logical(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek().kind
+		if !contains(levels[level], op) {
+			return left, nil
+		}
+		p.next()
+		right, err := p.logical(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if op == "||" || op == "&&" {
+			left = &node{logic: op, args: []*node{left, right}}
+		} else {
+			left = &node{op: binaryOps[op], args: []*node{left, right}}
+		}
+	}
+}
+
+func contains(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) unary() (*node, error) {
+	switch p.peek().kind {
+	case "-", "!":
+		op := opNeg
+		if p.next().kind == "!" {
+			op = opNot
+		}
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &node{op: op, args: []*node{n}}, nil
+	case "+":
+		p.next()
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *parser) power() (*node, error) {
+	base, err := p.primary()
+	if err != nil || p.peek().kind != "^" {
+		return base, err
+	}
+	p.next()
+	// right associative, and binds tighter than a unary minus on its left
+	exp, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &node{op: opPow, args: []*node{base, exp}}, nil
+}
+
+func (p *parser) primary() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case "num":
+		return &node{op: opConst, val: t.num}, nil
+	case "(":
+		n, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case "ident":
+		if p.peek().kind == "(" {
+			return p.call(t)
+		}
+		for i, v := range p.vars {
+			if v == t.text {
+				return &node{op: opVar, arg: i}, nil
+			}
+		}
+		switch t.text {
+		case "pi":
+			return &node{op: opConst, val: math.Pi}, nil
+		case "e":
+			return &node{op: opConst, val: math.E}, nil
+		}
+		return nil, fmt.Errorf("expr: unknown variable %q at %d", t.text, t.pos)
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) call(name token) (*node, error) {
+	index := -1
+	for i, f := range functions {
+		if f.name == name.text {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("expr: unknown function %q at %d", name.text, name.pos)
+	}
+	p.next() // (
+
+	n := &node{op: opCall, arg: index}
+	for p.peek().kind != ")" {
+		if len(n.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		n.args = append(n.args, arg)
+	}
+	p.next() // )
+
+	if want := functions[index].args; len(n.args) != want {
+		return nil, fmt.Errorf("expr: %s takes %d arguments, got %d", name.text, want, len(n.args))
+	}
+	return n, nil
+}
+
+// fold replaces parts of the tree that do not depend on any variable with
+// their value.
+func fold(n *node) *node {
+	constant := true
+	for i, a := range n.args {
+		n.args[i] = fold(a)
+		if n.args[i].op != opConst || n.args[i].logic != "" {
+			constant = false
+		}
+	}
+	if !constant || n.op == opVar || (n.op == opConst && n.logic == "") {
+		return n
+	}
+
+	prog := &Program{}
+	prog.emit(n)
+	return &node{op: opConst, val: prog.Eval(&[MaxVars]float64{})}
+}
+
+func (prog *Program) emit(n *node) {
+	switch n.logic {
+	case "?":
+		prog.emit(n.args[0])
+		jumpElse := prog.add(instr{op: opJumpIfFalse})
+		prog.emit(n.args[1])
+		jumpEnd := prog.add(instr{op: opJump})
+		prog.code[jumpElse].arg = len(prog.code)
+		prog.emit(n.args[2])
+		prog.code[jumpEnd].arg = len(prog.code)
+		return
+	case "||", "&&":
+		prog.emit(n.args[0])
+		op := opJumpIfTrue
+		if n.logic == "&&" {
+			op = opJumpIfZero
+		}
+		jump := prog.add(instr{op: op})
+		prog.emit(n.args[1])
+		prog.add(instr{op: opBool})
+		prog.code[jump].arg = len(prog.code)
+		return
+	}
+
+	for _, a := range n.args {
+		prog.emit(a)
+	}
+	prog.add(instr{op: n.op, arg: n.arg, val: n.val})
+}
+
+func (prog *Program) add(in instr) int {
+	prog.code = append(prog.code, in)
+	return len(prog.code) - 1
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"life/settings"
+	"life/sim"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// The fields page adds and removes fields and edits the selected one. Right
+// clicking the world moves a well, vortex or shear line to the cursor.
+var fieldsPage = newPage("Fields", (*Game).drawFieldPanel)
+
+var fieldColour = color.RGBA{255, 200, 80, 255}
+
+func init() {
+	p := fieldsPage
+	p.plusMinus("kind", pageY, func(g *Game) {
+		g.fieldKind = (g.fieldKind + 1) % len(sim.FieldNames)
+	}, func(g *Game) {
+		g.fieldKind = (g.fieldKind + len(sim.FieldNames) - 1) % len(sim.FieldNames)
+	})
+	p.button("Add", 8, pageY+rowH, 88, func(g *Game) {
+		g.world.AddField(sim.DefaultField(sim.FieldNames[g.fieldKind]))
+		g.field = len(g.world.Fields) - 1
+	})
+	p.button("Remove", 104, pageY+rowH, 88, func(g *Game) {
+		if g.selectedField() != nil {
+			g.world.Fields = append(g.world.Fields[:g.field], g.world.Fields[g.field+1:]...)
+		}
+	})
+	p.plusMinus("field", pageY+2*rowH, func(g *Game) {
+		g.field++
+	}, func(g *Game) {
+		g.field--
+	})
+	p.plusMinus("strength", pageY+3*rowH, func(g *Game) {
+		if f := g.selectedField(); f != nil {
+			f.Strength += strengthStep(f.Kind)
+		}
+	}, func(g *Game) {
+		if f := g.selectedField(); f != nil {
+			f.Strength -= strengthStep(f.Kind)
+		}
+	})
+	p.plusMinus("shape", pageY+4*rowH, func(g *Game) {
+		g.reshapeField(1)
+	}, func(g *Game) {
+		g.reshapeField(-1)
+	})
+}
+
+// reshapeField turns the selected field by 15 degrees or grows it by 10
+// pixels, depending on its kind, or the other way when by is negative.
+func (g *Game) reshapeField(by int) {
+	f := g.selectedField()
+	if f == nil {
+		return
+	}
+	switch f.Kind {
+	case "gravity", "shear":
+		f.Angle = math.Mod(f.Angle+float64(15*by)+360, 360)
+	case "well", "vortex":
+		f.Radius = math.Max(f.Radius+float64(10*by), 10)
+	}
+}
+
+// strengthStep is how much the strength buttons change a field of the given
+// kind by: a fifth of its default.
+func strengthStep(kind string) float64 {
+	return sim.DefaultField(kind).Strength / 5
+}
+
+// selectedField clamps the selection to the fields there are and returns the
+// selected one, or nil if there are none.
+func (g *Game) selectedField() *sim.Field {
+	fields := g.world.Fields
+	if len(fields) == 0 {
+		g.field = 0
+		return nil
+	}
+	g.field = int(math.Max(0, math.Min(float64(g.field), float64(len(fields)-1))))
+	return &fields[g.field]
+}
+
+// positioned reports whether a field of the given kind has a position.
+func positioned(kind string) bool {
+	return kind == "well" || kind == "vortex" || kind == "shear"
+}
+
+func (g *Game) drawFieldPanel(screen *ebiten.Image) {
+	f := g.selectedField()
+	if f != nil && positioned(f.Kind) && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		if x, y := ebiten.CursorPosition(); x < settings.Width {
+			f.X, f.Y = float64(x), float64(y)
+		}
+	}
+
+	ebitenutil.DebugPrintAt(screen, "New: "+sim.FieldNames[g.fieldKind], settings.Width+8, pageY+2)
+	if f == nil {
+		ebitenutil.DebugPrintAt(screen, "No fields", settings.Width+8, pageY+2*rowH+2)
+		return
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%d/%d %s", g.field+1, len(g.world.Fields), f.Kind), settings.Width+8, pageY+2*rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Str: %.3g", f.Strength), settings.Width+8, pageY+3*rowH+2)
+	switch f.Kind {
+	case "gravity", "shear":
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Angle: %.0f", f.Angle), settings.Width+8, pageY+4*rowH+2)
+	case "well", "vortex":
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Radius: %.0f", f.Radius), settings.Width+8, pageY+4*rowH+2)
+	}
+	if positioned(f.Kind) {
+		ebitenutil.DebugPrintAt(screen, "Right click to place", settings.Width+8, pageY+5*rowH+2)
+	}
+}
+
+// drawFields marks where each well and vortex is, and the line each shear
+// flows along.
+func (g *Game) drawFields(screen *ebiten.Image) {
+	for _, f := range g.world.Fields {
+		switch f.Kind {
+		case "well", "vortex":
+			ebitenutil.DrawLine(screen, f.X-4, f.Y, f.X+4, f.Y, fieldColour)
+			ebitenutil.DrawLine(screen, f.X, f.Y-4, f.X, f.Y+4, fieldColour)
+			drawCircle(screen, f.X, f.Y, f.Radius, fieldColour)
+		case "shear":
+			sin, cos := math.Sincos(f.Angle * math.Pi / 180)
+			ebitenutil.DrawLine(screen, f.X-20*cos, f.Y-20*sin, f.X+20*cos, f.Y+20*sin, fieldColour)
+		}
+	}
+}
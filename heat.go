@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"life/settings"
+	"life/sim"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// The heat page sets the temperature and thermostat while the world runs, and
+// how particles collide.
+var heatPage = newPage("Heat", (*Game).drawHeatPanel)
+
+func init() {
+	p := heatPage
+	p.plusMinus("temperature", pageY, func(g *Game) {
+		settings.Temperature += 0.1
+	}, func(g *Game) {
+		settings.Temperature = math.Max(settings.Temperature-0.1, 0)
+	})
+	p.plusMinus("thermostat", pageY+rowH, func(g *Game) {
+		settings.Thermostat = cycleName(sim.ThermostatNames, settings.Thermostat, 1)
+	}, func(g *Game) {
+		settings.Thermostat = cycleName(sim.ThermostatNames, settings.Thermostat, -1)
+	})
+	p.plusMinus("tau", pageY+2*rowH, func(g *Game) {
+		settings.ThermostatTau += 5
+	}, func(g *Game) {
+		settings.ThermostatTau = math.Max(settings.ThermostatTau-5, 5)
+	})
+	p.plusMinus("collisions", pageY+4*rowH, func(g *Game) {
+		settings.Collisions = cycleName(sim.CollisionNames, settings.Collisions, 1)
+	}, func(g *Game) {
+		settings.Collisions = cycleName(sim.CollisionNames, settings.Collisions, -1)
+	})
+	p.plusMinus("restitution", pageY+5*rowH, func(g *Game) {
+		settings.CollisionRestitution = math.Min(settings.CollisionRestitution+0.1, 1)
+	}, func(g *Game) {
+		settings.CollisionRestitution = math.Max(settings.CollisionRestitution-0.1, 0)
+	})
+}
+
+func (g *Game) drawHeatPanel(screen *ebiten.Image) {
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Temp: %.1f", settings.Temperature), settings.Width+8, pageY+2)
+	ebitenutil.DebugPrintAt(screen, settings.Thermostat, settings.Width+8, pageY+rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Tau: %.0f", settings.ThermostatTau), settings.Width+8, pageY+2*rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Kinetic: %.2f", g.world.KineticTemperature()), settings.Width+8, pageY+3*rowH+2)
+	ebitenutil.DebugPrintAt(screen, "Hit: "+settings.Collisions, settings.Width+8, pageY+4*rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Bounce: %.1f", settings.CollisionRestitution), settings.Width+8, pageY+5*rowH+2)
+}
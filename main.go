@@ -1,43 +1,41 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"image/color"
 	"life/attract"
 	"life/settings"
+	"life/sim"
 	"log"
 	"math"
-	"math/rand"
 	"os"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
 	col "github.com/lucasb-eyer/go-colorful"
 )
 
+// RGBColours and Images hold the colour and image of each type, and grow as
+// types are added.
 var (
-	RGBColours = make([]color.RGBA, settings.MaxTypes)
-	Images     = make([]*ebiten.Image, settings.MaxTypes)
-	Attractors = make([]attract.AttractionFunction, settings.MaxTypes)
+	RGBColours []color.RGBA
+	Images     []*ebiten.Image
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-
-	for i := 0; i < 100; i++ {
-		RecomputeImages(i)
-
-		Attractors[i] = attract.DefaultAttractionFunc()
+func RecomputeImages(i, size int) {
+	for len(Images) <= i {
+		RGBColours = append(RGBColours, color.RGBA{})
+		Images = append(Images, nil)
 	}
-}
-
-func RecomputeImages(i int) {
-	RecomputeColours()
+	RecomputeColour(i)
 
 	var err error
-	Images[i], err = ebiten.NewImage(settings.ParticleSize, settings.ParticleSize, ebiten.FilterLinear)
+	Images[i], err = ebiten.NewImage(size, size, ebiten.FilterLinear)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -64,63 +62,21 @@ func RecomputeColour(i int) {
 }
 
 func RecomputeColours() {
-	for j := 0; j < settings.MaxTypes; j++ {
+	for j := range RGBColours {
 		RecomputeColour(j)
 	}
 }
 
-func dist(x1, y1, x2, y2 float64) float64 {
-	// return math.Sqrt(math.Pow(x2-x1, 2) + math.Pow(y2-y1, 2))
-	// manhattan distance is faster
-	return math.Abs(x2-x1) + math.Abs(y2-y1)
-}
-
-type Particle struct {
-	X, Y     float64
-	Velocity [2]float64
-	Type     int8
-}
-
-func (p *Particle) UpdateVelocity(other Particle, attract attract.AttractionFunction) {
-	d := dist(p.X, p.Y, other.X, other.Y)
-	p.Velocity[0] += (other.X - p.X) / d * attract(d, p.Type, other.Type)
-	p.Velocity[1] += (other.Y - p.Y) / d * attract(d, p.Type, other.Type)
-}
-
-func (p *Particle) UpdatePosition() {
-	// Teleport to other side of screen if out of bounds
-	if p.X < 0 {
-		p.X = settings.Width
-	}
-	if p.X > settings.Width {
-		p.X = 0
-	}
-
-	if p.Y < 0 {
-		p.Y = settings.Height
-	}
-	if p.Y > settings.Height {
-		p.Y = 0
-	}
-
-	// Update position
-	p.X += p.Velocity[0] * settings.Speed
-	p.Y += p.Velocity[1] * settings.Speed
-
-	// Friction
-	p.Velocity[0] *= settings.Friction
-	p.Velocity[1] *= settings.Friction
-}
-
 var clicks = map[string]int8{}
 var presses = map[ebiten.Key]int8{}
 
 var UI = map[[4]int][2]func(*Game){
 	{settings.Width + 6, 4, settings.UIWidth - 10, 30}: {
 		func(g *Game) {
-			g.Setup()
-			for i := 0; i < settings.MaxTypes; i++ {
-				RecomputeImages(i)
+			g.branch()
+			g.world.Setup()
+			for i := range g.world.Properties {
+				RecomputeImages(i, g.world.Properties[i].Size)
 			}
 		},
 		func(g *Game) {},
@@ -133,10 +89,11 @@ var UI = map[[4]int][2]func(*Game){
 		},
 		func(g *Game) {
 			if clicks["types++"] == 1 {
-				settings.Types++
-				settings.Types = int(math.Min(float64(settings.Types), float64(settings.MaxTypes)))
+				g.world.SetTypes(int(math.Min(float64(settings.Types+1), float64(settings.MaxTypes))))
 				clicks["types++"] = 0
 				Labels[[2]int{settings.Width + 8, 42}] = fmt.Sprintf("Types: %d", settings.Types)
+				t := settings.Types - 1
+				RecomputeImages(t, g.world.Properties[t].Size)
 				RecomputeColours()
 			}
 		},
@@ -148,8 +105,8 @@ var UI = map[[4]int][2]func(*Game){
 		func(g *Game) {
 			if clicks["types--"] == 1 {
 				clicks["types--"] = 0
-				settings.Types--
-				settings.Types = int(math.Max(float64(settings.Types), 1))
+				g.world.SetTypes(int(math.Max(float64(settings.Types-1), 1)))
+				g.selectedType = int(math.Min(float64(g.selectedType), float64(settings.Types-1)))
 				Labels[[2]int{settings.Width + 8, 42}] = fmt.Sprintf("Types: %d", settings.Types)
 				RecomputeColours()
 			}
@@ -225,10 +182,8 @@ var UI = map[[4]int][2]func(*Game){
 		},
 		func(g *Game) {
 			if clicks["friction++"] == 1 {
-				settings.Friction += 0.01
-				settings.Friction = math.Min(settings.Friction, 1)
+				g.shiftFriction(0.01)
 				clicks["friction++"] = 0
-				Labels[[2]int{settings.Width + 8, 162}] = fmt.Sprintf("Friction: %.2f", settings.Friction)
 			}
 		},
 	},
@@ -239,9 +194,7 @@ var UI = map[[4]int][2]func(*Game){
 		func(g *Game) {
 			if clicks["friction--"] == 1 {
 				clicks["friction--"] = 0
-				settings.Friction -= 0.01
-				settings.Friction = math.Max(settings.Friction, 0)
-				Labels[[2]int{settings.Width + 8, 162}] = fmt.Sprintf("Friction: %.2f", settings.Friction)
+				g.shiftFriction(-0.01)
 			}
 		},
 	},
@@ -307,12 +260,8 @@ var UI = map[[4]int][2]func(*Game){
 		},
 		func(g *Game) {
 			if clicks["size++"] == 1 {
-				settings.ParticleSize += 1
+				g.shiftSize(1)
 				clicks["size++"] = 0
-				Labels[[2]int{settings.Width + 8, 282}] = fmt.Sprintf("Size: %d", settings.ParticleSize)
-				for i := 0; i < 100; i++ {
-					RecomputeImages(i)
-				}
 			}
 		},
 	},
@@ -323,33 +272,25 @@ var UI = map[[4]int][2]func(*Game){
 		func(g *Game) {
 			if clicks["size--"] == 1 {
 				clicks["size--"] = 0
-				settings.ParticleSize -= 1
-				settings.ParticleSize = int(math.Max(float64(settings.ParticleSize), 0))
-				Labels[[2]int{settings.Width + 8, 282}] = fmt.Sprintf("Size: %d", settings.ParticleSize)
-				for i := 0; i < 100; i++ {
-					RecomputeImages(i)
-				}
+				g.shiftSize(-1)
 			}
 		},
 	},
 
-	{settings.Width + 8, 503, (settings.UIWidth - 16) / 2, 30}: {
-		func(g *Game) {
-			attract.AttractionMatrix = make([][]float64, settings.MaxTypes)
-			for i := 0; i < settings.MaxTypes; i++ {
-				attract.AttractionMatrix[i] = make([]float64, settings.MaxTypes)
-			}
-		}, func(g *Game) {},
-	},
-	{settings.Width + 4 + (settings.UIWidth)/2, 503, (settings.UIWidth - 16) / 2, 30}: {
-		func(g *Game) {
+	{settings.Width + 8, 503, (settings.UIWidth - 16) / 2, 30}: clickOnce("clear", func(g *Game) {
+		g.transform(func() {
 			for i := range attract.AttractionMatrix {
 				for j := range attract.AttractionMatrix[i] {
-					attract.AttractionMatrix[i][j] = 2*rand.Float64() - 1
+					attract.AttractionMatrix[i][j] = 0
 				}
 			}
-		}, func(g *Game) {},
-	},
+		})
+	}),
+	{settings.Width + 4 + (settings.UIWidth)/2, 503, (settings.UIWidth - 16) / 2, 30}: clickOnce("random", func(g *Game) {
+		g.transform(func() {
+			attract.RandomizeAttractionMatrix(g.world.Rand)
+		})
+	}),
 }
 
 var Labels = map[[2]int]string{
@@ -380,139 +321,247 @@ var Labels = map[[2]int]string{
 }
 
 type Game struct {
-	particles       []Particle
+	world           *sim.World
+	lastUpdate      time.Time
 	matrixEditorLoc [2]int
-	darkTheme       bool
+	selectedType    int
+	page            int
+	// curvePoint is the force curve point being dragged, or -1
+	curvePoint int
+	// dragFrom is where the drag tool last moved its group to
+	dragFrom [2]float64
+	dragging bool
+	// history holds attraction matrices to go back to, most recent last
+	history [][][]float64
+	// editorCells is how many types the matrix editor shows across, or 0
+	// for all of them, and editorScroll the first one shown on each axis.
+	editorCells  int
+	editorScroll [2]int
+	editRadius   bool
+	// fieldKind is the index in sim.FieldNames of the kind of field the
+	// fields page adds, and field the one it edits.
+	fieldKind int
+	field     int
+	// rewind holds the recent history of the world. While rewinding the
+	// world is paused on snapshot frame, and replaying steps through them.
+	rewind     *sim.Rewind
+	frame      int
+	rewinding  bool
+	replaying  bool
+	replayTime float64
+	darkTheme  bool
+	showHelp   bool
+	prompt     *prompt
 }
 
-func (g *Game) Update(screen *ebiten.Image) error {
-	var wg sync.WaitGroup
-	for i := range g.particles {
-		wg.Add(1)
-		go func(i int) {
-			for j := range g.particles {
-				if i == j {
-					continue
-				}
-				g.particles[i].UpdateVelocity(g.particles[j], Attractors[g.particles[i].Type])
-
-				// Allow for overflow to other side of screen
-				g.particles[i].UpdateVelocity(Particle{
-					X:    g.particles[j].X + settings.Width,
-					Y:    g.particles[j].Y,
-					Type: g.particles[j].Type,
-				}, Attractors[g.particles[i].Type])
-				g.particles[i].UpdateVelocity(Particle{
-					X:    g.particles[j].X - settings.Width,
-					Y:    g.particles[j].Y,
-					Type: g.particles[j].Type,
-				}, Attractors[g.particles[i].Type])
-				g.particles[i].UpdateVelocity(Particle{
-					X:    g.particles[j].X,
-					Y:    g.particles[j].Y + settings.Height,
-					Type: g.particles[j].Type,
-				}, Attractors[g.particles[i].Type])
-				g.particles[i].UpdateVelocity(Particle{
-					X:    g.particles[j].X,
-					Y:    g.particles[j].Y - settings.Height,
-					Type: g.particles[j].Type,
-				}, Attractors[g.particles[i].Type])
-			}
-			wg.Done()
-		}(i)
+// RulesetChanged brings the panel and images up to date after the rules were
+// replaced wholesale, e.g. by loading a ruleset.
+func (g *Game) RulesetChanged() {
+	Labels[[2]int{settings.Width + 8, 42}] = fmt.Sprintf("Types: %d", settings.Types)
+	for i := range g.world.Properties {
+		RecomputeImages(i, g.world.Properties[i].Size)
 	}
+	g.selectedType = int(math.Min(float64(g.selectedType), float64(settings.Types-1)))
+}
 
-	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			x, y := ebiten.CursorPosition()
-			for i := range g.particles {
-				g.particles[i].UpdateVelocity(Particle{
-					X:    float64(x),
-					Y:    float64(y),
-					Type: g.particles[i].Type,
-				}, attract.MouseAttraction)
-			}
-		}()
+// cycleName returns the name by places after cur in names, or before it when
+// by is negative, wrapping around at either end. A cur that is not in names
+// gives the first.
+func cycleName(names []string, cur string, by int) string {
+	for n := range names {
+		if names[n] == cur {
+			return names[((n+by)%len(names)+len(names))%len(names)]
+		}
 	}
+	return names[0]
+}
 
-	wg.Wait()
+func (g *Game) Update(screen *ebiten.Image) error {
+	g.applyTool()
 
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		for i := range g.particles {
-			g.particles[i].UpdatePosition()
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		if ebiten.IsKeyPressed(ebiten.KeyF11) {
-			presses[ebiten.KeyF11] = 1
-		} else if ebiten.IsKeyPressed(ebiten.KeyUp) {
-			presses[ebiten.KeyUp] = 1
-		} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
-			presses[ebiten.KeyDown] = 1
-		} else if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-			presses[ebiten.KeyLeft] = 1
-		} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
-			presses[ebiten.KeyRight] = 1
-		} else if ebiten.IsKeyPressed(ebiten.KeyQ) {
-			presses[ebiten.KeyQ] = 1
-		} else if ebiten.IsKeyPressed(ebiten.KeyE) {
-			presses[ebiten.KeyE] = 1
-		} else if ebiten.IsKeyPressed(ebiten.KeyEscape) {
-			os.Exit(0)
-		}
+	// physics runs on a fixed step, independent of the TPS
+	now := time.Now()
+	if g.rewinding {
+		g.replay(now.Sub(g.lastUpdate).Seconds())
+	} else {
+		g.world.Advance(now.Sub(g.lastUpdate).Seconds())
+		g.record()
+	}
+	g.lastUpdate = now
 
-		for i, v := range presses {
-			if v == 1 {
-				if !ebiten.IsKeyPressed(i) {
-					presses[i] = 0
-
-					switch i {
-					case ebiten.KeyUp:
-						g.matrixEditorLoc[1]--
-						g.matrixEditorLoc[1] = int(math.Max(0, float64(g.matrixEditorLoc[1])))
-					case ebiten.KeyDown:
-						g.matrixEditorLoc[1]++
-						g.matrixEditorLoc[1] = int(math.Min(float64(settings.Types-1), float64(g.matrixEditorLoc[1])))
-					case ebiten.KeyLeft:
-						g.matrixEditorLoc[0]--
-						g.matrixEditorLoc[0] = int(math.Max(0, float64(g.matrixEditorLoc[0])))
-					case ebiten.KeyRight:
-						g.matrixEditorLoc[0]++
-						g.matrixEditorLoc[0] = int(math.Min(float64(settings.Types-1), float64(g.matrixEditorLoc[0])))
-					case ebiten.KeyQ:
-						attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] += 0.1
-						if attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] > 1 {
-							attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] = 1
-						} else if attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] < -1 {
-							attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] = -1
-						}
-					case ebiten.KeyE:
-						attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] -= 0.1
-						if attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] > 1 {
-							attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] = 1
-						} else if attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] < -1 {
-							attract.AttractionMatrix[g.matrixEditorLoc[0]][g.matrixEditorLoc[1]] = -1
-						}
-					case ebiten.KeyF11:
-						ebiten.SetFullscreen(!ebiten.IsFullscreen())
-					}
+	if g.prompt != nil {
+		g.updatePrompt()
+		return nil
+	}
+	g.updateEditor()
+
+	if ebiten.IsKeyPressed(ebiten.KeyF11) {
+		presses[ebiten.KeyF11] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyUp) {
+		presses[ebiten.KeyUp] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyDown) {
+		presses[ebiten.KeyDown] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+		presses[ebiten.KeyLeft] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyRight) {
+		presses[ebiten.KeyRight] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyQ) {
+		presses[ebiten.KeyQ] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyE) {
+		presses[ebiten.KeyE] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyTab) {
+		presses[ebiten.KeyTab] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyK) {
+		presses[ebiten.KeyK] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyX) {
+		presses[ebiten.KeyX] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyB) {
+		presses[ebiten.KeyB] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyI) {
+		presses[ebiten.KeyI] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyC) {
+		presses[ebiten.KeyC] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyT) {
+		presses[ebiten.KeyT] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyP) {
+		presses[ebiten.KeyP] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key1) {
+		presses[ebiten.Key1] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key2) {
+		presses[ebiten.Key2] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key3) {
+		presses[ebiten.Key3] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key4) {
+		presses[ebiten.Key4] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key5) {
+		presses[ebiten.Key5] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key6) {
+		presses[ebiten.Key6] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key7) {
+		presses[ebiten.Key7] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key8) {
+		presses[ebiten.Key8] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key9) {
+		presses[ebiten.Key9] = 1
+	} else if ebiten.IsKeyPressed(ebiten.Key0) {
+		presses[ebiten.Key0] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyZ) {
+		presses[ebiten.KeyZ] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyEqual) {
+		presses[ebiten.KeyEqual] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyMinus) {
+		presses[ebiten.KeyMinus] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyH) {
+		presses[ebiten.KeyH] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyF5) {
+		presses[ebiten.KeyF5] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyF6) {
+		presses[ebiten.KeyF6] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyF7) {
+		presses[ebiten.KeyF7] = 1
+	} else if ebiten.IsKeyPressed(ebiten.KeyF9) {
+		presses[ebiten.KeyF9] = 1
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		// only a fresh press, so the one that closed a prompt does not exit
+		os.Exit(0)
+	}
 
-					g.matrixEditorLoc = [2]int{
-						int(math.Max(0, math.Min(float64(len(Attractors)-1), float64(g.matrixEditorLoc[0])))),
-						int(math.Max(0, math.Min(float64(len(Attractors)-1), float64(g.matrixEditorLoc[1])))),
+	for i, v := range presses {
+		if v == 1 {
+			if !ebiten.IsKeyPressed(i) {
+				presses[i] = 0
+
+				switch i {
+				case ebiten.KeyUp:
+					g.matrixEditorLoc[1]--
+					g.matrixEditorLoc[1] = int(math.Max(0, float64(g.matrixEditorLoc[1])))
+				case ebiten.KeyDown:
+					g.matrixEditorLoc[1]++
+					g.matrixEditorLoc[1] = int(math.Min(float64(settings.Types-1), float64(g.matrixEditorLoc[1])))
+				case ebiten.KeyLeft:
+					g.matrixEditorLoc[0]--
+					g.matrixEditorLoc[0] = int(math.Max(0, float64(g.matrixEditorLoc[0])))
+				case ebiten.KeyRight:
+					g.matrixEditorLoc[0]++
+					g.matrixEditorLoc[0] = int(math.Min(float64(settings.Types-1), float64(g.matrixEditorLoc[0])))
+				case ebiten.KeyQ:
+					g.nudge(1)
+				case ebiten.KeyE:
+					g.nudge(-1)
+				case ebiten.KeyTab:
+					g.editRadius = !g.editRadius
+				case ebiten.KeyK:
+					i, j := g.matrixEditorLoc[0], g.matrixEditorLoc[1]
+					g.world.SetKernel(i, j, cycleName(attract.KernelNames, g.world.Kernels[i][j], 1))
+				case ebiten.KeyX:
+					g.prompt = expressionPrompt()
+				case ebiten.KeyF11:
+					ebiten.SetFullscreen(!ebiten.IsFullscreen())
+				case ebiten.KeyB:
+					settings.Boundary = cycleName(sim.BoundaryNames, settings.Boundary, 1)
+				case ebiten.KeyI:
+					settings.Integrator = cycleName(sim.IntegratorNames, settings.Integrator, 1)
+				case ebiten.KeyC:
+					settings.Collisions = cycleName(sim.CollisionNames, settings.Collisions, 1)
+				case ebiten.KeyT:
+					settings.Tool = cycleName(Tools, settings.Tool, 1)
+				case ebiten.KeyP:
+					g.world.Timeline.Playing = !g.world.Timeline.Playing
+				case ebiten.Key1:
+					g.transform(attract.Transpose)
+				case ebiten.Key2:
+					g.transform(attract.Symmetrize)
+				case ebiten.Key3:
+					g.transform(attract.Antisymmetrize)
+				case ebiten.Key4:
+					g.transform(attract.Negate)
+				case ebiten.Key5:
+					g.transform(func() { attract.Scale(1.25) })
+				case ebiten.Key6:
+					g.transform(func() { attract.Scale(0.8) })
+				case ebiten.Key7:
+					g.transform(attract.Rotate)
+				case ebiten.Key8:
+					g.shuffleMatrix()
+				case ebiten.Key9:
+					g.mutateMatrix()
+				case ebiten.Key0:
+					g.swapTypes()
+				case ebiten.KeyZ:
+					g.undo()
+				case ebiten.KeyEqual:
+					g.zoomEditor(1)
+				case ebiten.KeyMinus:
+					g.zoomEditor(-1)
+				case ebiten.KeyH:
+					g.showHelp = !g.showHelp
+				case ebiten.KeyF5:
+					if err := g.world.SaveRuleset(settings.RulesetPath); err != nil {
+						log.Println(err)
 					}
+				case ebiten.KeyF6:
+					if err := g.world.SaveSnapshot(settings.SnapshotPath); err != nil {
+						log.Println(err)
+					}
+				case ebiten.KeyF7:
+					if err := g.world.LoadSnapshot(settings.SnapshotPath); err != nil {
+						log.Println(err)
+					}
+					g.RulesetChanged()
+				case ebiten.KeyF9:
+					if err := g.world.LoadRuleset(settings.RulesetPath); err != nil {
+						log.Println(err)
+					}
+					g.RulesetChanged()
+				}
+
+				g.matrixEditorLoc = [2]int{
+					int(math.Max(0, math.Min(float64(settings.Types-1), float64(g.matrixEditorLoc[0])))),
+					int(math.Max(0, math.Min(float64(settings.Types-1), float64(g.matrixEditorLoc[1])))),
 				}
 			}
 		}
-	}()
-
-	wg.Wait()
+	}
 
 	return nil
 }
@@ -521,14 +570,18 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	// Onclick Events
 	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
 		x, y := ebiten.CursorPosition()
-		for k, v := range UI {
-			if x >= k[0] && x <= k[0]+k[2] && y >= k[1] && y <= k[1]+k[3] {
-				v[0](g)
+		for _, ui := range g.activeUI() {
+			for k, v := range ui {
+				if x >= k[0] && x <= k[0]+k[2] && y >= k[1] && y <= k[1]+k[3] {
+					v[0](g)
+				}
 			}
 		}
 	} else {
-		for k := range UI {
-			UI[k][1](g)
+		for _, ui := range g.activeUI() {
+			for k := range ui {
+				ui[k][1](g)
+			}
 		}
 	}
 
@@ -539,166 +592,139 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	// UI
 	ebitenutil.DrawRect(screen, settings.Width+1, 0, 2, settings.Height, color.RGBA{100, 100, 100, 255})
 
-	for i := range UI {
-		ebitenutil.DrawRect(screen, float64(i[0]), float64(i[1]), float64(i[2]), float64(i[3]), color.RGBA{100, 100, 100, 255})
+	for _, ui := range g.activeUI() {
+		for i := range ui {
+			ebitenutil.DrawRect(screen, float64(i[0]), float64(i[1]), float64(i[2]), float64(i[3]), color.RGBA{100, 100, 100, 255})
+		}
 	}
 
 	for k, v := range Labels {
 		ebitenutil.DebugPrintAt(screen, v, k[0], k[1])
 	}
 
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Integrator: %s", settings.Integrator), settings.Width+12, settings.Height-76)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Boundary: %s", settings.Boundary), settings.Width+12, settings.Height-58)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Seed: %d", g.world.Seed), settings.Width+12, settings.Height-40)
 	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("FPS: %0.0f, TPS: %0.0f", ebiten.CurrentFPS(), ebiten.CurrentTPS()), settings.Width+12, settings.Height-22)
 
-	for _, p := range g.particles {
+	g.drawObstacles(screen)
+	g.drawFields(screen)
+	g.drawBonds(screen)
+	for _, p := range g.world.Particles {
 		op := &ebiten.DrawImageOptions{}
 		op.GeoM.Translate(p.X, p.Y)
 		screen.DrawImage(Images[p.Type], op)
 	}
 
-	// Editor
-	boxWidth := (settings.UIWidth - 40) / settings.Types
-	for i := 0; i < settings.Types; i++ {
-		// coloured headers
-		ebitenutil.DrawRect(
-			screen,
-			float64(settings.Width+24+(i*boxWidth)), float64(314),
-			float64(boxWidth), 10,
-			RGBColours[i],
-		)
-		ebitenutil.DrawRect(
+	g.drawCursor(screen)
+	g.drawEditor(screen)
+	drawGenerator(screen)
+	g.drawPage(screen)
+	if g.prompt != nil {
+		g.drawPrompt(screen)
+	}
+	ebitenutil.DebugPrintAt(screen, "H: Toggle Help", settings.Width+12, settings.Height-94)
+	if g.showHelp {
+		ebitenutil.DebugPrintAt(
 			screen,
-			float64(settings.Width+8), float64(328+(i*boxWidth)),
-			10, float64(boxWidth),
-			RGBColours[i],
+			"Esc: Exit\nF11: Toggle Fullscreen\nB: Cycle Boundary\nI: Cycle Integrator\nC: Cycle Collisions\nT: Cycle Mouse Tool\nP: Play/Pause Keyframes\n1-9, 0: Attraction Matrix\nOperations\nZ: Undo Attraction Change\nF5: Save Ruleset\nF9: Load Ruleset\nF6: Save Snapshot\nF7: Load Snapshot\nSome settings need a new\nenvironment before they update.\nSome update live.\nArrow keys or a click to move\neditor selection, = and - or\nthe wheel to zoom the editor. Q and E to change\nvalues, Tab to switch between\nattraction and radius, K to\ncycle the kernel of the cell,\nX to type a kernel for it.\nThe panel pages are switched\nwith < and >. On the Curve\npage drag points, click to\nadd, right click to remove.\nClick to interact.",
+			8, 8,
 		)
-
-		for j := 0; j < settings.Types; j++ {
-			colour := color.RGBA{20, 20, 20, 255}
-			if attract.AttractionMatrix[i][j] > 0 {
-				colour = color.RGBA{0, uint8(255 * attract.AttractionMatrix[i][j]), 0, 255}
-			} else if attract.AttractionMatrix[i][j] < 0 {
-				colour = color.RGBA{uint8(255 * (1 - attract.AttractionMatrix[i][j])), 0, 0, 255}
-			}
-
-			ebitenutil.DrawRect(
-				screen,
-				float64(settings.Width+24+(i*boxWidth)), float64(328+(j*boxWidth)),
-				float64(boxWidth), float64(boxWidth),
-				colour,
-			)
-			if settings.Types < 7 {
-				ebitenutil.DebugPrintAt(
-					screen,
-					fmt.Sprintf("%0.1f", attract.AttractionMatrix[i][j]),
-					settings.Width+24+(i*boxWidth)+boxWidth/2-10,
-					328+(j*boxWidth)+boxWidth/2-5,
-				)
-			}
-		}
 	}
-
-	// add small white border to editor selection (editorLoc)
-	ebitenutil.DrawRect(
-		screen,
-		float64(settings.Width+24+(g.matrixEditorLoc[0]*boxWidth)),
-		float64(328+(g.matrixEditorLoc[1]*boxWidth)),
-		float64(boxWidth), 4,
-		color.RGBA{255, 255, 255, 255},
-	)
-
-	ebitenutil.DebugPrintAt(
-		screen,
-		"Esc: Exit\nF11: Toggle Fullscreen\nSome settings need a new\nenvironment before they update.\nSome update live.\nArrow keys to move editor\nselection. Q and E to change\nvalues. Click to interact.",
-		settings.Width+6, settings.Height-200,
-	)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
 	return settings.Width + settings.UIWidth, settings.Height
 }
 
-func (g *Game) Setup() {
-	g.particles = make([]Particle, 0, settings.NParticles)
-	switch settings.Arrangement {
-	case "random":
-		for i := 0; i < settings.NParticles; i++ {
-			g.particles = append(g.particles, Particle{
-				X:    rand.Float64() * settings.Width,
-				Y:    rand.Float64() * settings.Height,
-				Type: int8(rand.Intn(settings.Types)),
-			})
-		}
-	case "circle":
-		for i := 0; i < settings.NParticles; i++ {
-			angle := float64(i) * 2 * math.Pi / float64(settings.NParticles)
-			g.particles = append(g.particles, Particle{
-				X:    settings.Width/2 + math.Cos(angle)*settings.Width/2,
-				Y:    settings.Height/2 + math.Sin(angle)*settings.Height/2,
-				Type: int8(rand.Intn(settings.Types)),
-			})
-		}
-	case "f_circle": // filled circle
-		for i := 0; i < settings.NParticles; i++ {
-			angle := float64(i) * 2 * math.Pi / float64(settings.NParticles)
-			g.particles = append(g.particles, Particle{
-				X:    settings.Width/2 + math.Cos(angle)*settings.Width/2 + 20*(rand.Float64()-.5),
-				Y:    settings.Height/2 + math.Sin(angle)*settings.Height/2 + 20*(rand.Float64()-.5),
-				Type: int8(rand.Intn(settings.Types)),
-			})
-		}
-	case "concentric":
-		for ring := 0; ring < settings.Types; ring++ {
-			for i := 0; i < settings.NParticles/settings.Types; i++ {
-				angle := float64(i) * 2 * math.Pi / float64(settings.NParticles/settings.Types)
-				g.particles = append(g.particles, Particle{
-					X:    float64(settings.Width/2+math.Cos(angle)*settings.Width/2*float64(ring)/float64(settings.Types)) + rand.Float64() - .5,
-					Y:    float64(settings.Height/2+math.Sin(angle)*settings.Height/2*float64(ring)/float64(settings.Types)) + rand.Float64() - .5,
-					Type: int8(ring),
-				})
-			}
-		}
-	case "line":
-		for i := 0; i < settings.NParticles; i++ {
-			g.particles = append(g.particles, Particle{
-				X:    float64(i) * settings.Width / float64(settings.NParticles),
-				Y:    settings.Height/2 + rand.Float64() - .5,
-				Type: int8(rand.Intn(settings.Types)),
-			})
-		}
-	case "grid":
-		for x := 0; x < int(math.Sqrt(float64(settings.NParticles))); x++ {
-			for y := 0; y < int(math.Sqrt(float64(settings.NParticles))); y++ {
-				g.particles = append(g.particles, Particle{
-					X:    float64(x)*settings.Width/math.Sqrt(float64(settings.NParticles)) + rand.Float64() - .5,
-					Y:    float64(y)*settings.Height/math.Sqrt(float64(settings.NParticles)) + rand.Float64() - .5,
-					Type: int8(rand.Intn(settings.Types)),
-				})
-			}
-		}
-	case "row":
-		for t := 0; t < settings.Types; t++ {
-			for i := 0; i < settings.NParticles/settings.Types; i++ {
-				g.particles = append(g.particles, Particle{
-					X:    (settings.Width/float64(settings.Types))*(float64(t)+rand.Float64()) - 20,
-					Y:    settings.Height/2 + 20*(rand.Float64()-.5),
-					Type: int8(t),
-				})
-			}
-		}
-	case "point":
-		for i := 0; i < settings.NParticles; i++ {
-			g.particles = append(g.particles, Particle{
-				X:    settings.Width/2 + rand.Float64() - .5,
-				Y:    settings.Height/2 + rand.Float64() - .5,
-				Type: int8(rand.Intn(settings.Types)),
-			})
+func main() {
+	var reactions []sim.Reaction
+	var fields []sim.Field
+	var bonds []sim.BondRule
+	flag.IntVar(&settings.Types, "types", settings.Types, fmt.Sprintf("number of particle types, at most %d", settings.MaxTypes))
+	flag.StringVar(&settings.Metric, "metric", settings.Metric, `distance metric, "euclidean", "manhattan" or "chebyshev"`)
+	flag.StringVar(&settings.Boundary, "boundary", settings.Boundary, `edges of the world, "wrap", "reflect", "absorb", "cylinder" or "klein"`)
+	flag.StringVar(&settings.Integrator, "integrator", settings.Integrator, `integrator, "euler", "verlet" or "rk4"`)
+	flag.Float64Var(&settings.Temperature, "temperature", settings.Temperature, "temperature, which adds velocity noise")
+	flag.StringVar(&settings.Thermostat, "thermostat", settings.Thermostat, `thermostat, "none", "berendsen" or "langevin"`)
+	flag.Float64Var(&settings.ThermostatTau, "tau", settings.ThermostatTau, "time over which the thermostat reaches the temperature")
+	flag.StringVar(&settings.Collisions, "collisions", settings.Collisions, `collisions between particles, "none", "elastic" or "inelastic"`)
+	flag.Float64Var(&settings.CollisionRestitution, "collision-restitution", settings.CollisionRestitution, "speed kept by inelastic collisions")
+	flag.Float64Var(&settings.DT, "dt", settings.DT, "simulated time per step")
+	flag.Float64Var(&settings.StepRate, "rate", settings.StepRate, "steps per second of real time")
+	flag.Float64Var(&settings.MinRadius, "min-radius", settings.MinRadius, "smallest interaction radius")
+	flag.Float64Var(&settings.MaxRadius, "max-radius", settings.MaxRadius, "largest interaction radius")
+	flag.Func("matrix", "attraction matrix generator, one of "+strings.Join(attract.GeneratorNames, ", ")+` (default "`+settings.AttractionSelection+`")`, func(v string) error {
+		settings.AttractionSelection = v
+		return attract.CheckGenerator(v)
+	})
+	flag.Float64Var(&settings.MatrixDensity, "density", settings.MatrixDensity, `fraction of entries set by the "sparse" generator`)
+	flag.IntVar(&settings.MatrixBand, "band", settings.MatrixBand, `entries either side of the diagonal set by the "banded" generator`)
+	flag.Float64Var(&settings.MatrixSigma, "sigma", settings.MatrixSigma, `standard deviation of the "gaussian" generator`)
+	flag.IntVar(&settings.MatrixRank, "rank", settings.MatrixRank, `rank of the "charge" generator`)
+	flag.StringVar(&settings.Kernel, "kernel", settings.Kernel, "pair kernel for every type, or a comma separated list for types 0, 1, 2, ...")
+	flag.Func("expr", "define a kernel as name=expression over "+strings.Join(attract.ExpressionVars, ", ")+", usable with -kernel", func(v string) error {
+		name, src, ok := strings.Cut(v, "=")
+		if !ok {
+			return errors.New("want name=expression")
 		}
-	default:
-		log.Fatal("Unknown arrangement.")
+		return attract.RegisterExpression(strings.TrimSpace(name), src)
+	})
+	flag.StringVar(&settings.Tool, "tool", settings.Tool, `mouse tool, one of "`+strings.Join(Tools, `", "`)+`"`)
+	flag.Func("reaction", "add a reaction rule such as 0+1>2 r=20 p=0.1, or 0+3*1>1 for 3 of type 1; repeatable", func(v string) error {
+		r, err := sim.ParseReaction(v)
+		reactions = append(reactions, r)
+		return err
+	})
+	flag.Func("field", "add a field such as gravity s=0.05 a=90 or well x=600 y=400 s=-1 r=50, one of "+strings.Join(sim.FieldNames, ", ")+"; repeatable", func(v string) error {
+		f, err := sim.ParseField(v)
+		fields = append(fields, f)
+		return err
+	})
+	flag.Func("bond", "add a bond rule such as 0-1 d=20 p=0.1 n=2 k=0.05 s=1; repeatable", func(v string) error {
+		r, err := sim.ParseBondRule(v)
+		bonds = append(bonds, r)
+		return err
+	})
+	flag.Int64Var(&settings.Seed, "seed", settings.Seed, "random seed, 0 picks one from the clock")
+	flag.StringVar(&settings.RulesetPath, "rules", settings.RulesetPath, "ruleset file, loaded on start if it exists and saved to with F5")
+	flag.StringVar(&settings.SnapshotPath, "snapshot", settings.SnapshotPath, "snapshot file, saved to with F6 and loaded with F7")
+	flag.IntVar(&settings.RewindEvery, "rewind-every", settings.RewindEvery, "ticks between the snapshots kept to rewind to")
+	flag.IntVar(&settings.RewindFrames, "rewind-frames", settings.RewindFrames, "number of snapshots kept to rewind to")
+	obstacles := flag.String("obstacles", "", "JSON file with a list of obstacles to add")
+	mask := flag.String("mask", "", "PNG file whose dark pixels are solid, stretched over the world")
+	flag.Parse()
+	if err := attract.CheckGeneratorSettings(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := attract.CheckRadii(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := sim.CheckThermostat(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := sim.CheckTimestep(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := sim.CheckCollisions(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := sim.CheckRewind(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
 	}
-}
 
-func main() {
 	ebiten.SetWindowSize(settings.Width*settings.Scale+settings.UIWidth, settings.Height*settings.Scale)
 	ebiten.SetWindowTitle("Particle Life")
 	ebiten.SetWindowResizable(true)
@@ -706,8 +732,51 @@ func main() {
 	ebiten.SetRunnableOnUnfocused(true)
 	ebiten.SetMaxTPS(250)
 
-	game := Game{darkTheme: true}
-	game.Setup()
+	game := Game{
+		world:      sim.NewWorld(settings.Seed),
+		lastUpdate: time.Now(),
+		darkTheme:  true,
+		curvePoint: -1,
+		rewind:     sim.NewRewind(settings.RewindFrames),
+	}
+	log.Printf("seed: %d", game.world.Seed)
+	if err := game.world.LoadRuleset(settings.RulesetPath); err == nil {
+		game.world.Setup()
+	} else if !os.IsNotExist(err) {
+		log.Fatal(err)
+	}
+	for _, r := range reactions {
+		if err := game.world.AddReaction(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, f := range fields {
+		if err := game.world.AddField(f); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, r := range bonds {
+		if err := game.world.AddBondRule(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *obstacles != "" {
+		if err := game.world.LoadObstacles(*obstacles); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *mask != "" {
+		m, err := sim.LoadMask(*mask)
+		if err != nil {
+			log.Fatal(err)
+		}
+		game.world.Mask = m
+	}
+	if *obstacles != "" || *mask != "" {
+		// move particles out of the obstacles
+		game.world.Setup()
+	}
+	game.RulesetChanged()
 
 	if err := ebiten.RunGame(&game); err != nil {
 		panic(err)
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// generatorY is the row above the Clear and Random buttons that picks the
+// generator Random uses.
+const generatorY = 478
+
+func init() {
+	UI[[4]int{settings.Width + 104, generatorY, 44, rowH - 2}] = clickOnce("generator<", func(g *Game) {
+		settings.AttractionSelection = cycleName(attract.GeneratorNames, settings.AttractionSelection, -1)
+	})
+	UI[[4]int{settings.Width + 151, generatorY, 44, rowH - 2}] = clickOnce("generator>", func(g *Game) {
+		settings.AttractionSelection = cycleName(attract.GeneratorNames, settings.AttractionSelection, 1)
+	})
+	Labels[[2]int{settings.Width + 120, generatorY + 2}] = "<"
+	Labels[[2]int{settings.Width + 170, generatorY + 2}] = ">"
+}
+
+// drawGenerator names the generator the Random button uses.
+func drawGenerator(screen *ebiten.Image) {
+	ebitenutil.DebugPrintAt(screen, settings.AttractionSelection, settings.Width+8, generatorY+2)
+}
+
+// maxHistory is how many matrix changes can be undone.
+const maxHistory = 100
+
+// The matrix page transforms the whole attraction matrix. The editor selection
+// picks the two types Swap exchanges.
+var matrixPage = newPage("Matrix", (*Game).drawMatrixPanel)
+
+func init() {
+	p := matrixPage
+	p.button("Transpose", 8, pageY, 88, func(g *Game) { g.transform(attract.Transpose) })
+	p.button("Negate", 104, pageY, 88, func(g *Game) { g.transform(attract.Negate) })
+	p.button("Symmetric", 8, pageY+rowH, 88, func(g *Game) { g.transform(attract.Symmetrize) })
+	p.button("Antisym", 104, pageY+rowH, 88, func(g *Game) { g.transform(attract.Antisymmetrize) })
+	p.button("Rotate", 8, pageY+2*rowH, 88, func(g *Game) { g.transform(attract.Rotate) })
+	p.button("Shuffle", 104, pageY+2*rowH, 88, (*Game).shuffleMatrix)
+	p.button("Mutate", 8, pageY+3*rowH, 88, (*Game).mutateMatrix)
+	p.button("Swap", 104, pageY+3*rowH, 88, (*Game).swapTypes)
+	p.plusMinus("sigma", pageY+4*rowH, func(g *Game) {
+		settings.MutateSigma += 0.05
+	}, func(g *Game) {
+		settings.MutateSigma = math.Max(settings.MutateSigma-0.05, 0.05)
+	})
+	p.button("x1.25", 8, pageY+5*rowH, 58, func(g *Game) { g.transform(func() { attract.Scale(1.25) }) })
+	p.button("x0.8", 70, pageY+5*rowH, 58, func(g *Game) { g.transform(func() { attract.Scale(0.8) }) })
+	p.button("Undo", 132, pageY+5*rowH, 60, (*Game).undo)
+}
+
+// transform remembers the attraction matrix so it can be undone, then runs
+// fn to change it.
+func (g *Game) transform(fn func()) {
+	g.history = append(g.history, attract.CopyMatrix())
+	if len(g.history) > maxHistory {
+		g.history = g.history[1:]
+	}
+	fn()
+}
+
+// undo puts back the attraction matrix from before the last change.
+func (g *Game) undo() {
+	if len(g.history) == 0 {
+		return
+	}
+	attract.RestoreMatrix(g.history[len(g.history)-1])
+	g.history = g.history[:len(g.history)-1]
+}
+
+// nudge changes the selected cell like nudgeSelected, remembering attraction
+// changes so they can be undone.
+func (g *Game) nudge(steps float64) {
+	if g.editRadius {
+		g.nudgeSelected(steps)
+		return
+	}
+	g.transform(func() { g.nudgeSelected(steps) })
+}
+
+func (g *Game) shuffleMatrix() {
+	g.transform(func() { attract.Shuffle(g.world.Rand) })
+}
+
+func (g *Game) mutateMatrix() {
+	g.transform(func() { attract.Mutate(g.world.Rand, settings.MutateSigma) })
+}
+
+// swapTypes exchanges the attractions of the two types of the selected matrix
+// cell.
+func (g *Game) swapTypes() {
+	i, j := g.matrixEditorLoc[0], g.matrixEditorLoc[1]
+	if i >= settings.Types || j >= settings.Types {
+		return
+	}
+	g.transform(func() { attract.Swap(i, j) })
+}
+
+func (g *Game) drawMatrixPanel(screen *ebiten.Image) {
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Sigma: %.2f", settings.MutateSigma), settings.Width+8, pageY+4*rowH+2)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"life/settings"
+	"life/sim"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// The morph page builds a timeline of attraction matrix keyframes out of the
+// matrix as it is edited, and plays it back.
+var morphPage = newPage("Morph", (*Game).drawMorphPanel)
+
+func init() {
+	p := morphPage
+	p.plusMinus("keys", pageY, func(g *Game) {
+		g.world.Timeline.AddKeyframe(settings.MorphTicks)
+	}, func(g *Game) {
+		tl := &g.world.Timeline
+		if len(tl.Keyframes) > 0 {
+			tl.Keyframes = tl.Keyframes[:len(tl.Keyframes)-1]
+			tl.Tick = int(math.Min(float64(tl.Tick), float64(tl.Length())))
+		}
+	})
+	p.plusMinus("ticks", pageY+rowH, func(g *Game) {
+		settings.MorphTicks += 60
+	}, func(g *Game) {
+		settings.MorphTicks = int(math.Max(float64(settings.MorphTicks-60), 60))
+	})
+	p.button("Play", 8, pageY+2*rowH, 88, func(g *Game) {
+		g.world.Timeline.Playing = !g.world.Timeline.Playing
+	})
+	p.button("Rewind", 104, pageY+2*rowH, 88, func(g *Game) {
+		g.world.Timeline.Tick = 0
+		g.world.Timeline.Apply()
+	})
+	p.button("Loop", 8, pageY+3*rowH, 88, func(g *Game) {
+		g.world.Timeline.Loop = !g.world.Timeline.Loop
+	})
+	p.button("Easing", 104, pageY+3*rowH, 88, func(g *Game) {
+		tl := &g.world.Timeline
+		if tl.Easing == "" {
+			tl.Easing = "linear"
+		}
+		tl.Easing = cycleName(sim.EasingNames, tl.Easing, 1)
+	})
+}
+
+func (g *Game) drawMorphPanel(screen *ebiten.Image) {
+	tl := &g.world.Timeline
+	state := "Paused"
+	if tl.Playing {
+		state = "Playing"
+	}
+	if tl.Loop {
+		state += ", looped"
+	}
+	easing := tl.Easing
+	if easing == "" {
+		easing = "linear"
+	}
+
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Keys: %d", len(tl.Keyframes)), settings.Width+8, pageY+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Ticks: %d", settings.MorphTicks), settings.Width+8, pageY+rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%s, %s\nTick %d of %d", state, easing, tl.Tick, tl.Length()), settings.Width+8, pageY+4*rowH+2)
+}
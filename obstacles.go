@@ -0,0 +1,68 @@
+package main
+
+import (
+	"image/color"
+	"life/settings"
+	"life/sim"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+var obstacleColour = color.RGBA{180, 180, 180, 255}
+
+// the mask is drawn from an image that is only rebuilt when it changes
+var (
+	maskImage *ebiten.Image
+	maskDrawn *sim.Mask
+)
+
+func (g *Game) drawObstacles(screen *ebiten.Image) {
+	if m := g.world.Mask; m != nil {
+		if m != maskDrawn {
+			maskImage, maskDrawn = maskToImage(m), m
+		}
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(settings.Width/float64(m.Width), settings.Height/float64(m.Height))
+		screen.DrawImage(maskImage, op)
+	}
+
+	for _, o := range g.world.Obstacles {
+		for _, e := range o.Edges() {
+			ebitenutil.DrawLine(screen, e[0][0], e[0][1], e[1][0], e[1][1], obstacleColour)
+		}
+		if o.Kind == "circle" {
+			drawCircle(screen, o.Points[0][0], o.Points[0][1], o.Radius, obstacleColour)
+		}
+	}
+}
+
+// drawCircle outlines a circle of radius r around x, y.
+func drawCircle(screen *ebiten.Image, x, y, r float64, c color.Color) {
+	const n = 48
+	for i := 0; i < n; i++ {
+		a, b := 2*math.Pi*float64(i)/n, 2*math.Pi*float64(i+1)/n
+		ebitenutil.DrawLine(screen, x+r*math.Cos(a), y+r*math.Sin(a), x+r*math.Cos(b), y+r*math.Sin(b), c)
+	}
+}
+
+func maskToImage(m *sim.Mask) *ebiten.Image {
+	pix := make([]byte, 4*m.Width*m.Height)
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.At(x, y) {
+				copy(pix[4*(y*m.Width+x):], []byte{90, 90, 90, 255})
+			}
+		}
+	}
+	img, err := ebiten.NewImage(m.Width, m.Height, ebiten.FilterDefault)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := img.ReplacePixels(pix); err != nil {
+		log.Fatal(err)
+	}
+	return img
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"life/settings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// The lower part of the panel is split into pages, shown one at a time, each
+// with its own buttons and labels.
+const (
+	pageTabY = 538
+	pageY    = 564
+	rowH     = 22
+)
+
+type page struct {
+	name   string
+	ui     map[[4]int][2]func(*Game)
+	labels map[[2]int]string
+	draw   func(g *Game, screen *ebiten.Image)
+}
+
+// pages are in the order the tab buttons step through them.
+var pages = []*page{typesPage, toolsPage, matrixPage, heatPage, curvePage, morphPage, reactPage, fieldsPage, bondsPage, rewindPage}
+
+func newPage(name string, draw func(g *Game, screen *ebiten.Image)) *page {
+	return &page{
+		name:   name,
+		ui:     map[[4]int][2]func(*Game){},
+		labels: map[[2]int]string{},
+		draw:   draw,
+	}
+}
+
+// clickOnce returns a UI handler pair that runs fn once, when the mouse is
+// released after pressing the element.
+func clickOnce(key string, fn func(g *Game)) [2]func(*Game) {
+	return [2]func(*Game){
+		func(g *Game) {
+			clicks[key] = 1
+		},
+		func(g *Game) {
+			if clicks[key] == 1 {
+				clicks[key] = 0
+				fn(g)
+			}
+		},
+	}
+}
+
+// button adds a button of width w at x, y on the page.
+func (p *page) button(label string, x, y, w int, fn func(g *Game)) {
+	p.ui[[4]int{settings.Width + x, y, w, rowH - 2}] = clickOnce(p.name+label, fn)
+	p.labels[[2]int{settings.Width + x + w/2 - 3*len(label), y + 2}] = label
+}
+
+// plusMinus adds a + and - button pair on the row at y, like the ones for the
+// global settings.
+func (p *page) plusMinus(key string, y int, inc, dec func(g *Game)) {
+	p.ui[[4]int{settings.Width + 104, y, 44, rowH - 2}] = clickOnce(p.name+key+"++", inc)
+	p.ui[[4]int{settings.Width + 151, y, 44, rowH - 2}] = clickOnce(p.name+key+"--", dec)
+	p.labels[[2]int{settings.Width + 120, y + 2}] = "+"
+	p.labels[[2]int{settings.Width + 170, y + 2}] = "-"
+}
+
+func init() {
+	UI[[4]int{settings.Width + 104, pageTabY, 44, rowH - 2}] = clickOnce("page<", func(g *Game) {
+		g.page = (g.page + len(pages) - 1) % len(pages)
+	})
+	UI[[4]int{settings.Width + 151, pageTabY, 44, rowH - 2}] = clickOnce("page>", func(g *Game) {
+		g.page = (g.page + 1) % len(pages)
+	})
+	Labels[[2]int{settings.Width + 120, pageTabY + 2}] = "<"
+	Labels[[2]int{settings.Width + 170, pageTabY + 2}] = ">"
+}
+
+// activeUI returns the elements that are currently on screen: the fixed ones
+// and those of the current page.
+func (g *Game) activeUI() []map[[4]int][2]func(*Game) {
+	return []map[[4]int][2]func(*Game){UI, pages[g.page].ui}
+}
+
+func (g *Game) drawPage(screen *ebiten.Image) {
+	p := pages[g.page]
+	ebitenutil.DebugPrintAt(screen, p.name, settings.Width+8, pageTabY+2)
+	for k, v := range p.labels {
+		ebitenutil.DebugPrintAt(screen, v, k[0], k[1])
+	}
+	p.draw(g, screen)
+}
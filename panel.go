@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// The types page edits the physical properties and kernels of one type at a
+// time.
+var typesPage = newPage("Types", (*Game).drawTypePanel)
+
+func init() {
+	p := typesPage
+	p.plusMinus("type", pageY, func(g *Game) {
+		g.selectedType = (g.selectedType + 1) % settings.Types
+	}, func(g *Game) {
+		g.selectedType = (g.selectedType + settings.Types - 1) % settings.Types
+	})
+	p.plusMinus("mass", pageY+rowH, func(g *Game) {
+		g.world.Properties[g.selectedType].Mass += 0.1
+	}, func(g *Game) {
+		prop := &g.world.Properties[g.selectedType]
+		prop.Mass = math.Max(prop.Mass-0.1, 0.1)
+	})
+	p.plusMinus("friction", pageY+2*rowH, func(g *Game) {
+		prop := &g.world.Properties[g.selectedType]
+		prop.Friction = math.Min(prop.Friction+0.01, 1)
+	}, func(g *Game) {
+		prop := &g.world.Properties[g.selectedType]
+		prop.Friction = math.Max(prop.Friction-0.01, 0)
+	})
+	p.plusMinus("maxspeed", pageY+3*rowH, func(g *Game) {
+		g.world.Properties[g.selectedType].MaxSpeed += 1
+	}, func(g *Game) {
+		prop := &g.world.Properties[g.selectedType]
+		prop.MaxSpeed = math.Max(prop.MaxSpeed-1, 0)
+	})
+	p.plusMinus("size", pageY+4*rowH, func(g *Game) {
+		g.world.Properties[g.selectedType].Size++
+		RecomputeImages(g.selectedType, g.world.Properties[g.selectedType].Size)
+	}, func(g *Game) {
+		prop := &g.world.Properties[g.selectedType]
+		prop.Size = int(math.Max(float64(prop.Size-1), 1))
+		RecomputeImages(g.selectedType, prop.Size)
+	})
+	p.plusMinus("kernel", pageY+5*rowH, func(g *Game) {
+		g.cycleKernel(1)
+	}, func(g *Game) {
+		g.cycleKernel(-1)
+	})
+}
+
+// shiftFriction moves the default friction, and that of every type, by d.
+// Types keep the differences set on this page, except where one reaches 0
+// or 1.
+func (g *Game) shiftFriction(d float64) {
+	settings.Friction = math.Max(0, math.Min(settings.Friction+d, 1))
+	for i := range g.world.Properties {
+		prop := &g.world.Properties[i]
+		prop.Friction = math.Max(0, math.Min(prop.Friction+d, 1))
+	}
+	Labels[[2]int{settings.Width + 8, 162}] = fmt.Sprintf("Friction: %.2f", settings.Friction)
+}
+
+// shiftSize grows the default size, and that of every type, by d, keeping
+// the differences between types except where one reaches 1.
+func (g *Game) shiftSize(d int) {
+	settings.ParticleSize = int(math.Max(float64(settings.ParticleSize+d), 1))
+	for i := range g.world.Properties {
+		prop := &g.world.Properties[i]
+		prop.Size = int(math.Max(float64(prop.Size+d), 1))
+		RecomputeImages(i, prop.Size)
+	}
+	Labels[[2]int{settings.Width + 8, 282}] = fmt.Sprintf("Size: %d", settings.ParticleSize)
+}
+
+// cycleKernel moves every force felt by the selected type on to the kernel
+// after the one it feels from itself in attract.KernelNames, or back when by
+// is negative.
+func (g *Game) cycleKernel(by int) {
+	t := g.selectedType
+	g.world.SetTypeKernel(t, cycleName(attract.KernelNames, g.world.Kernels[t][t], by))
+}
+
+// typeKernel names the kernel every force felt by type t uses, or "mixed".
+func (g *Game) typeKernel(t int) string {
+	row := g.world.Kernels[t]
+	for ot := 0; ot < settings.Types; ot++ {
+		if row[ot] != row[t] {
+			return "mixed"
+		}
+	}
+	return row[t]
+}
+
+// drawTypePanel shows the properties of the selected type next to their
+// buttons.
+func (g *Game) drawTypePanel(screen *ebiten.Image) {
+	ebitenutil.DrawRect(screen, settings.Width+80, pageY+3, 14, 14, RGBColours[g.selectedType])
+
+	prop := g.world.Properties[g.selectedType]
+	maxSpeed := "off"
+	if prop.MaxSpeed > 0 {
+		maxSpeed = fmt.Sprintf("%.0f", prop.MaxSpeed)
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Type: %d", g.selectedType), settings.Width+8, pageY+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Mass: %.1f", prop.Mass), settings.Width+8, pageY+rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Friction: %.2f", prop.Friction), settings.Width+8, pageY+2*rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Max Vel: %s", maxSpeed), settings.Width+8, pageY+3*rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Size: %d", prop.Size), settings.Width+8, pageY+4*rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Kern: %s", g.typeKernel(g.selectedType)), settings.Width+8, pageY+5*rowH+2)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"life/attract"
+	"life/settings"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// prompt is a line of text typed over the bottom of the viewport, e.g. to
+// define kernels from expressions. submit is given the text on Enter, and the
+// prompt closes unless it returns an error.
+type prompt struct {
+	help   string
+	submit func(g *Game, text string) error
+	text   string
+	err    string
+}
+
+func expressionPrompt() *prompt {
+	return &prompt{
+		help:   "Kernel for the selected cell, using " + strings.Join(attract.ExpressionVars, " ") + ". Enter to apply, Esc to cancel.",
+		submit: (*Game).submitExpression,
+	}
+}
+
+// updatePrompt handles typing while the prompt is open. Every other key
+// binding is ignored until it closes.
+func (g *Game) updatePrompt() {
+	g.prompt.text += string(ebiten.InputChars())
+	if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(g.prompt.text) > 0 {
+		runes := []rune(g.prompt.text)
+		g.prompt.text = string(runes[:len(runes)-1])
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.prompt = nil
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		if err := g.prompt.submit(g, g.prompt.text); err != nil {
+			g.prompt.err = err.Error()
+		} else {
+			g.prompt = nil
+		}
+	}
+}
+
+// submitExpression registers the typed kernel and gives it to the selected
+// cell of the matrix editor. The text is either an expression, which gets a
+// new name, or name=expression, which also replaces an earlier definition.
+func (g *Game) submitExpression(text string) error {
+	name, src, ok := strings.Cut(text, "=")
+	if ok && !strings.ContainsAny(name, "<>!=") && !strings.HasPrefix(src, "=") {
+		name = strings.TrimSpace(name)
+	} else {
+		name, src = fmt.Sprintf("expr%d", len(attract.Expressions)+1), text
+	}
+
+	if err := attract.RegisterExpression(name, src); err != nil {
+		return err
+	}
+	g.world.RebuildKernel(name)
+	return g.world.SetKernel(g.matrixEditorLoc[0], g.matrixEditorLoc[1], name)
+}
+
+func (g *Game) drawPrompt(screen *ebiten.Image) {
+	ebitenutil.DrawRect(screen, 0, settings.Height-56, settings.Width, 56, color.RGBA{30, 30, 30, 230})
+	help := g.prompt.help
+	if g.prompt.err != "" {
+		help = g.prompt.err
+	}
+	ebitenutil.DebugPrintAt(screen, help, 8, settings.Height-52)
+	ebitenutil.DebugPrintAt(screen, "> "+g.prompt.text+"_", 8, settings.Height-30)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"life/settings"
+	"life/sim"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// The react page lists the reaction rules and charts the population of each
+// type.
+var reactPage = newPage("React", (*Game).drawReactPanel)
+
+// reactionLines is how many rules the page lists, newest last.
+const reactionLines = 3
+
+const (
+	chartY = pageY + rowH + 16*reactionLines + 18
+	chartH = 46
+)
+
+func init() {
+	p := reactPage
+	p.button("Add", 8, pageY, 88, func(g *Game) {
+		g.prompt = &prompt{
+			help:   "Reaction as A+B>C or A+n*B>C, with r=radius and p=probability. Enter to add, Esc to cancel.",
+			submit: (*Game).submitReaction,
+		}
+	})
+	p.button("Remove", 104, pageY, 88, func(g *Game) {
+		if n := len(g.world.Reactions); n > 0 {
+			g.world.Reactions = g.world.Reactions[:n-1]
+		}
+	})
+}
+
+func (g *Game) submitReaction(text string) error {
+	r, err := sim.ParseReaction(text)
+	if err != nil {
+		return err
+	}
+	return g.world.AddReaction(r)
+}
+
+func (g *Game) drawReactPanel(screen *ebiten.Image) {
+	reactions := g.world.Reactions
+	if len(reactions) > reactionLines {
+		reactions = reactions[len(reactions)-reactionLines:]
+	}
+	for n, r := range reactions {
+		ebitenutil.DebugPrintAt(screen, r.String(), settings.Width+8, pageY+rowH+16*n)
+	}
+	if len(reactions) == 0 {
+		ebitenutil.DebugPrintAt(screen, "No reactions", settings.Width+8, pageY+rowH)
+	}
+
+	population := g.world.Population()
+	largest := 1
+	for _, n := range population {
+		if n > largest {
+			largest = n
+		}
+	}
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Type %d: %d of %d", g.selectedType, population[g.selectedType], len(g.world.Particles)), settings.Width+8, chartY-18)
+
+	// one bar per type, which can be narrower than a pixel with many types
+	barW := float64(settings.UIWidth-16) / float64(len(population))
+	for t, n := range population {
+		h := float64(chartH * n / largest)
+		ebitenutil.DrawRect(screen, float64(settings.Width+8)+float64(t)*barW, chartY+chartH-h, barW, h, RGBColours[t])
+	}
+}
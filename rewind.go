@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"life/settings"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// The rewind page scrubs through the snapshots in g.rewind. Stepping back
+// pauses the world on a snapshot, from where it can be replayed forward one
+// snapshot at a time, or branched off into a new run that replaces the
+// snapshots after it.
+var rewindPage = newPage("Rewind", (*Game).drawRewindPanel)
+
+const (
+	scrubX = settings.Width + 8
+	scrubY = pageY + rowH
+	scrubW = settings.UIWidth - 16
+	scrubH = rowH - 2
+)
+
+func init() {
+	p := rewindPage
+	p.button("<", 8, pageY, 56, func(g *Game) {
+		if g.pause() {
+			g.seek(g.frame - 1)
+		}
+	})
+	p.button("Play", 72, pageY, 56, func(g *Game) {
+		g.replaying = g.rewinding && !g.replaying
+	})
+	p.button(">", 136, pageY, 56, func(g *Game) {
+		if g.rewinding {
+			g.seek(g.frame + 1)
+		}
+	})
+	p.ui[[4]int{scrubX, scrubY, scrubW, scrubH}] = [2]func(*Game){(*Game).scrub, func(g *Game) {}}
+	p.button("Branch", 8, pageY+3*rowH, 88, (*Game).branch)
+	p.button("Live", 104, pageY+3*rowH, 88, func(g *Game) {
+		if g.rewinding {
+			g.seek(g.rewind.Len() - 1)
+			g.rewinding, g.replaying = false, false
+		}
+	})
+	p.plusMinus("every", pageY+4*rowH, func(g *Game) {
+		settings.RewindEvery += 30
+	}, func(g *Game) {
+		settings.RewindEvery = int(math.Max(float64(settings.RewindEvery-30), 30))
+	})
+}
+
+// branch runs the world on from the snapshot it is paused on, dropping the
+// snapshots after it.
+func (g *Game) branch() {
+	if g.rewinding {
+		g.rewind.Truncate(g.frame + 1)
+		g.rewinding, g.replaying = false, false
+	}
+}
+
+// record keeps a snapshot of the world every settings.RewindEvery ticks.
+func (g *Game) record() {
+	if err := g.rewind.Record(g.world); err != nil {
+		log.Println(err)
+	}
+}
+
+// pause stops the world on a snapshot of itself, pushed as the newest, so
+// Live can return to the moment it was paused. It returns false if there is
+// no snapshot to be on.
+func (g *Game) pause() bool {
+	if !g.rewinding {
+		if err := g.rewind.Push(g.world); err != nil {
+			log.Println(err)
+			return false
+		}
+		if g.rewind.Len() == 0 {
+			return false
+		}
+		g.rewinding = true
+		g.frame = g.rewind.Len() - 1
+	}
+	return true
+}
+
+// seek moves the paused world to snapshot i.
+func (g *Game) seek(i int) {
+	i = int(math.Max(0, math.Min(float64(i), float64(g.rewind.Len()-1))))
+	if err := g.rewind.Restore(g.world, i); err != nil {
+		log.Println(err)
+		return
+	}
+	g.frame = i
+	g.RulesetChanged()
+}
+
+// scrub seeks to the snapshot under the cursor while the scrubber is held.
+func (g *Game) scrub() {
+	if !g.pause() {
+		return
+	}
+	n := g.rewind.Len()
+	x, _ := ebiten.CursorPosition()
+	i := int(math.Min(float64(x-scrubX)/scrubW*float64(n), float64(n-1)))
+	if i != g.frame {
+		g.seek(i)
+	}
+}
+
+// replay moves on to the next snapshot as often as the world would reach it
+// running live, and stops at the last one.
+func (g *Game) replay(elapsed float64) {
+	if !g.replaying {
+		return
+	}
+	g.replayTime += elapsed
+	if g.replayTime < float64(settings.RewindEvery)/settings.StepRate {
+		return
+	}
+	g.replayTime = 0
+	if g.frame+1 < g.rewind.Len() {
+		g.seek(g.frame + 1)
+	} else {
+		g.replaying = false
+	}
+}
+
+func (g *Game) drawRewindPanel(screen *ebiten.Image) {
+	n := g.rewind.Len()
+	at := 1.
+	if g.rewinding && n > 1 {
+		at = float64(g.frame) / float64(n-1)
+	}
+	ebitenutil.DrawRect(screen, scrubX, scrubY, at*scrubW, scrubH, color.RGBA{160, 160, 160, 255})
+	ebitenutil.DrawRect(screen, scrubX+at*(scrubW-3), scrubY, 3, scrubH, color.RGBA{255, 220, 0, 255})
+
+	state := fmt.Sprintf("Live, %d kept", n)
+	if g.rewinding {
+		state = fmt.Sprintf("Tick %d, %d/%d", g.world.Ticks, g.frame+1, n)
+		if g.replaying {
+			state += " >"
+		}
+	}
+	ebitenutil.DebugPrintAt(screen, state, settings.Width+8, pageY+2*rowH+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Every: %d", settings.RewindEvery), settings.Width+8, pageY+4*rowH+2)
+}
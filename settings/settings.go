@@ -10,15 +10,11 @@ const (
 	Scale        = 1.
 	Width        = 1200
 	Height       = 800
-	MaxTypes     = 100
-	MaxParticles = 10000
+	MaxTypes     = 1000
+	MaxParticles = 50000
 
 	// Optional Attraction Settings
-	AttractionSelection = "random"
-	RadiiSelection      = "random"
-
-	MinRadius = 100
-	MaxRadius = 200
+	RadiiSelection = "random"
 
 	// UI Settings
 	UIWidth = 200
@@ -34,6 +30,61 @@ var (
 	Types         = 5
 	NParticles    = 300
 
+	// Kernel names the pair kernel for every type, or a comma separated list
+	// of kernels for types 0, 1, 2, ...
+	Kernel = "default"
+
+	// Physics Settings
+	Integrator  = "euler"     // "euler", "verlet" or "rk4"
+	DT          = 1.          // simulated time per step
+	StepRate    = 120.        // steps per second of real time
+	MaxSubsteps = 4           // most steps run for a single frame
+	Metric      = "manhattan" // "euclidean", "manhattan" or "chebyshev"
+	Boundary    = "wrap"      // "wrap", "reflect", "absorb", "cylinder" or "klein"
+	Restitution = .9          // speed kept when bouncing off a wall
+
+	// Collision Settings
+	Collisions           = "none" // "none", "elastic" or "inelastic"
+	CollisionRestitution = .5     // speed kept by inelastic collisions between particles
+
+	// Thermostat Settings
+	Temperature   = 0.     // target temperature, and strength of the velocity noise
+	Thermostat    = "none" // "none", "berendsen" or "langevin"
+	ThermostatTau = 20.    // time over which the thermostat reaches the temperature
+
+	// Attraction matrix generator, one of attract.GeneratorNames, and the
+	// parameters of those that take one
+	AttractionSelection = "random"
+	MatrixDensity       = .3 // fraction of entries set by "sparse"
+	MatrixBand          = 1  // entries either side of the diagonal set by "banded"
+	MatrixSigma         = .5 // standard deviation of "gaussian"
+	MatrixRank          = 1  // rank of "charge"
+	MutateSigma         = .1 // deviation of the noise added by a mutation
+
+	// Steps the matrix takes to morph away from a keyframe added in the panel
+	MorphTicks = 600
+
+	// Interaction radii, between which the radius matrix is drawn
+	MinRadius = 100.
+	MaxRadius = 200.
+
+	// Mouse Tool Settings
+	Tool         = "repel" // "attract", "repel", "vortex", "spawn", "erase", "drag" or "wall"
+	ToolRadius   = 60.
+	ToolStrength = 1. // impulse at the cursor, or particles spawned per frame
+
+	// Neighbour Search Settings
+	NeighbourSearch = "grid" // "grid" or "all"
+
+	// File Settings
+	RulesetPath  = "ruleset.json"
+	SnapshotPath = "snapshot.json.gz"
+
+	// Rewind Settings
+	RewindEvery  = 60  // ticks between the snapshots kept to rewind to
+	RewindFrames = 200 // snapshots kept, the oldest being dropped first
+
 	// Randomization Settings
-	RandomFunc = rand.Float64
+	Seed       int64 = 0 // 0 picks a seed from the clock
+	RandomFunc       = (*rand.Rand).Float64
 )
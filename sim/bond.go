@@ -0,0 +1,271 @@
+package sim
+
+import (
+	"fmt"
+	"life/settings"
+	"strconv"
+	"strings"
+)
+
+// BondRule lets particles of types A and B that come within Distance of each
+// other bond with the given Probability each step, as long as both have fewer
+// than Max bonds. The bond is a spring of the given Stiffness whose rest
+// length is Distance, and it breaks once it is stretched by more than Strain
+// times its rest length.
+type BondRule struct {
+	A, B        int
+	Distance    float64
+	Probability float64
+	Max         int
+	Stiffness   float64
+	Strain      float64
+}
+
+// Bond is a spring between particles I and J, which formed under a BondRule
+// and keeps its parameters even if the rule is removed.
+type Bond struct {
+	I, J      int
+	Length    float64
+	Stiffness float64
+	Strain    float64
+}
+
+// ParseBondRule reads a bond rule written as
+//
+//	A-B d=20 p=0.1 n=2 k=0.05 s=1
+//
+// where A and B are types, d the distance, p the probability, n the most bonds
+// a particle can have, k the stiffness and s the strain it breaks at. The
+// distance defaults to settings.RepelRadius doubled and the rest to the values
+// above, except the probability, which defaults to 1.
+func ParseBondRule(s string) (BondRule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return BondRule{}, fmt.Errorf("empty bond rule")
+	}
+	r := BondRule{Distance: 2 * settings.RepelRadius, Probability: 1, Max: 2, Stiffness: .05, Strain: 1}
+
+	a, b, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return r, fmt.Errorf("want A-B, got %q", fields[0])
+	}
+	if err := parseInt(a, &r.A, "type"); err != nil {
+		return r, err
+	}
+	if err := parseInt(b, &r.B, "type"); err != nil {
+		return r, err
+	}
+
+	for _, opt := range fields[1:] {
+		key, value, _ := strings.Cut(opt, "=")
+		var err error
+		switch key {
+		case "d":
+			r.Distance, err = strconv.ParseFloat(value, 64)
+		case "p":
+			r.Probability, err = strconv.ParseFloat(value, 64)
+		case "n":
+			err = parseInt(value, &r.Max, "count")
+		case "k":
+			r.Stiffness, err = strconv.ParseFloat(value, 64)
+		case "s":
+			r.Strain, err = strconv.ParseFloat(value, 64)
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, r.validate(settings.MaxTypes)
+}
+
+func (r BondRule) String() string {
+	return fmt.Sprintf("%d-%d d=%g p=%g n=%d k=%g s=%g", r.A, r.B, r.Distance, r.Probability, r.Max, r.Stiffness, r.Strain)
+}
+
+// validate checks the rule only involves the first types types.
+func (r BondRule) validate(types int) error {
+	for _, t := range []int{r.A, r.B} {
+		if t < 0 || t >= types {
+			return fmt.Errorf("type %d out of range, want 0 to %d", t, types-1)
+		}
+	}
+	if r.Distance <= 0 {
+		return fmt.Errorf("distance %g, want more than 0", r.Distance)
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability %g, want 0 to 1", r.Probability)
+	}
+	if r.Max < 1 {
+		return fmt.Errorf("at most %d bonds, want at least 1", r.Max)
+	}
+	if r.Strain <= 0 {
+		return fmt.Errorf("strain %g, want more than 0", r.Strain)
+	}
+	return nil
+}
+
+// matches reports whether the rule bonds particles of types t and ot.
+func (r BondRule) matches(t, ot uint16) bool {
+	return int(t) == r.A && int(ot) == r.B || int(t) == r.B && int(ot) == r.A
+}
+
+// AddBondRule adds r after the other bond rules, as long as it only involves
+// active types.
+func (w *World) AddBondRule(r BondRule) error {
+	if err := r.validate(settings.Types); err != nil {
+		return err
+	}
+	w.BondRules = append(w.BondRules, r)
+	return nil
+}
+
+// link indexes the bonds by particle, so each particle can find its own.
+func (w *World) link() {
+	n := len(w.Particles)
+	for len(w.links) < n {
+		w.links = append(w.links, nil)
+	}
+	w.links = w.links[:n]
+	for i := range w.links {
+		w.links[i] = w.links[i][:0]
+	}
+	for b, bond := range w.Bonds {
+		w.links[bond.I] = append(w.links[bond.I], b)
+		w.links[bond.J] = append(w.links[bond.J], b)
+	}
+}
+
+// bondForce returns the force the springs bonding particle i pull it with.
+func (w *World) bondForce(ps []Particle, i int) [2]float64 {
+	var a [2]float64
+	if i >= len(w.links) {
+		return a
+	}
+	p := ps[i]
+	for _, b := range w.links[i] {
+		bond := w.Bonds[b]
+		j := bond.I
+		if j == i {
+			j = bond.J
+		}
+		dx, dy, d := w.boundary.displacement(p.X, p.Y, ps[j].X, ps[j].Y, w.metric)
+		if d == 0 {
+			continue
+		}
+		f := bond.Stiffness * (d - bond.Length)
+		a[0] += dx / d * f
+		a[1] += dy / d * f
+	}
+	return a
+}
+
+// dropBonds removes the particles that are not alive and renumbers the bonds
+// between the rest, dropping those that lost a particle.
+func (w *World) dropBonds(alive []bool) {
+	if len(w.Bonds) == 0 {
+		return
+	}
+	index := make([]int, len(alive))
+	n := 0
+	for i, ok := range alive {
+		index[i] = n
+		if ok {
+			n++
+		}
+	}
+	kept := w.Bonds[:0]
+	for _, b := range w.Bonds {
+		if alive[b.I] && alive[b.J] {
+			b.I, b.J = index[b.I], index[b.J]
+			kept = append(kept, b)
+		}
+	}
+	w.Bonds = kept
+}
+
+// bond breaks the bonds that are strained too far, then forms new ones. Pairs
+// in reach are found in parallel, then bond in particle order, drawing from
+// w.Rand, so runs stay reproducible.
+func (w *World) bond() {
+	if len(w.Bonds) == 0 && len(w.BondRules) == 0 {
+		return
+	}
+	ps := w.Particles
+	kept := w.Bonds[:0]
+	for _, b := range w.Bonds {
+		_, _, d := w.boundary.displacement(ps[b.I].X, ps[b.I].Y, ps[b.J].X, ps[b.J].Y, w.metric)
+		if d-b.Length <= b.Strain*b.Length {
+			kept = append(kept, b)
+		}
+	}
+	w.Bonds = kept
+	w.link()
+	if len(w.BondRules) == 0 {
+		return
+	}
+
+	// a grid with cells as large as the largest formation distance
+	reach := 0.
+	for _, r := range w.BondRules {
+		reach = maxFloat(reach, r.Distance)
+	}
+	if w.bondGrid == nil || w.bondGrid.CellSize != reach || w.bondGrid.Boundary != w.boundary {
+		w.bondGrid = NewGrid(settings.Width, settings.Height, reach, w.boundary)
+	}
+	w.bondGrid.Build(ps)
+
+	// the rule each later particle in reach would bond under, as pairs of
+	// particle and rule
+	for len(w.reach) < len(ps) {
+		w.reach = append(w.reach, nil)
+	}
+	w.reach = w.reach[:len(ps)]
+	workers.run(len(ps), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			w.reach[i] = w.reach[i][:0]
+			p := ps[i]
+			w.bondGrid.Neighbours(i, func(j int) {
+				if j < i {
+					return
+				}
+				other := ps[j]
+				for k, r := range w.BondRules {
+					if !r.matches(p.Type, other.Type) {
+						continue
+					}
+					if _, _, d := w.boundary.displacement(p.X, p.Y, other.X, other.Y, w.metric); d <= r.Distance {
+						w.reach[i] = append(w.reach[i], [2]int{j, k})
+						return
+					}
+				}
+			})
+		}
+	})
+
+	for i := range ps {
+		for _, c := range w.reach[i] {
+			j, r := c[0], w.BondRules[c[1]]
+			if len(w.links[i]) >= r.Max || len(w.links[j]) >= r.Max || w.bonded(i, j) {
+				continue
+			}
+			if r.Probability < 1 && w.Rand.Float64() >= r.Probability {
+				continue
+			}
+			w.Bonds = append(w.Bonds, Bond{I: i, J: j, Length: r.Distance, Stiffness: r.Stiffness, Strain: r.Strain})
+			w.links[i] = append(w.links[i], len(w.Bonds)-1)
+			w.links[j] = append(w.links[j], len(w.Bonds)-1)
+		}
+	}
+}
+
+// bonded reports whether particles i and j are already bonded.
+func (w *World) bonded(i, j int) bool {
+	for _, b := range w.links[i] {
+		if w.Bonds[b].I == j || w.Bonds[b].J == j {
+			return true
+		}
+	}
+	return false
+}
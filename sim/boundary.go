@@ -0,0 +1,111 @@
+package sim
+
+import (
+	"life/settings"
+	"log"
+	"math"
+)
+
+// Boundary decides what happens to particles at the edges of the world, and
+// how displacements between particles are measured across those edges. Any
+// axis that does not wrap is closed off by walls.
+type Boundary struct {
+	WrapX, WrapY bool
+	// FlipX mirrors x whenever a particle crosses the top or bottom edge,
+	// which turns a torus into a Klein bottle.
+	FlipX bool
+	// Absorb removes particles that hit a wall instead of reflecting them.
+	Absorb bool
+}
+
+var Boundaries = map[string]Boundary{
+	"wrap":     {WrapX: true, WrapY: true},
+	"reflect":  {},
+	"absorb":   {Absorb: true},
+	"cylinder": {WrapX: true},
+	"klein":    {WrapX: true, WrapY: true, FlipX: true},
+}
+
+// BoundaryNames lists the modes in Boundaries in a stable order, e.g. for
+// cycling through them.
+var BoundaryNames = []string{"wrap", "reflect", "absorb", "cylinder", "klein"}
+
+func currentBoundary() Boundary {
+	b, ok := Boundaries[settings.Boundary]
+	if !ok {
+		log.Fatal("Unknown boundary.")
+	}
+	return b
+}
+
+// confine applies the boundary to a particle that has just moved. It returns
+// false if the particle was absorbed.
+func (b Boundary) confine(p *Particle) bool {
+	if b.WrapX {
+		p.X = wrap(p.X, settings.Width)
+	} else if !wall(&p.X, &p.Velocity[0], settings.Width, b.Absorb) {
+		return false
+	}
+
+	if b.WrapY {
+		if p.Y < 0 || p.Y >= settings.Height {
+			p.Y = wrap(p.Y, settings.Height)
+			if b.FlipX {
+				p.X = settings.Width - p.X
+				p.Velocity[0] = -p.Velocity[0]
+			}
+		}
+	} else if !wall(&p.Y, &p.Velocity[1], settings.Height, b.Absorb) {
+		return false
+	}
+	return true
+}
+
+// wall reflects a coordinate back inside [0, size], losing speed according to
+// settings.Restitution. It returns false if the coordinate is outside and the
+// wall absorbs.
+func wall(x, v *float64, size float64, absorb bool) bool {
+	if *x >= 0 && *x <= size {
+		return true
+	}
+	if absorb {
+		return false
+	}
+	if *x < 0 {
+		*x = -*x
+	} else {
+		*x = 2*size - *x
+	}
+	// still outside after a very large step
+	*x = math.Max(0, math.Min(size, *x))
+	*v = -*v * settings.Restitution
+	return true
+}
+
+// displacement returns the shortest displacement from x1, y1 to x2, y2 under
+// the boundary, along with its length in the metric m. On wrapped axes this is
+// the minimum image, so each pair interacts exactly once.
+func (b Boundary) displacement(x1, y1, x2, y2 float64, m Metric) (float64, float64, float64) {
+	dx, dy := x2-x1, y2-y1
+	if b.WrapX {
+		dx -= settings.Width * math.Round(dx/settings.Width)
+	}
+	if !b.WrapY {
+		return dx, dy, m(dx, dy)
+	}
+	if !b.FlipX {
+		dy -= settings.Height * math.Round(dy/settings.Height)
+		return dx, dy, m(dx, dy)
+	}
+
+	// Klein bottle: the nearest image is either the particle itself or its
+	// mirror image across the nearer of the top and bottom edges.
+	d := m(dx, dy)
+	fdx := settings.Width - x2 - x1
+	fdx -= settings.Width * math.Round(fdx/settings.Width)
+	fdy := dy - math.Copysign(settings.Height, dy)
+	if fd := m(fdx, fdy); fd < d {
+		return fdx, fdy, fd
+	}
+	return dx, dy, d
+}
@@ -0,0 +1,131 @@
+package sim
+
+import (
+	"fmt"
+	"life/settings"
+	"log"
+)
+
+// CollisionNames lists the collision modes in a stable order. With "none"
+// particles pass through each other, "elastic" collisions keep all of the
+// speed along the line between the particles and "inelastic" ones keep
+// settings.CollisionRestitution of it.
+var CollisionNames = []string{"none", "elastic", "inelastic"}
+
+// CheckCollisions returns an error unless settings.CollisionRestitution is
+// between 0 and 1.
+func CheckCollisions() error {
+	if !(settings.CollisionRestitution >= 0 && settings.CollisionRestitution <= 1) {
+		return fmt.Errorf("collision restitution %g, want 0 to 1", settings.CollisionRestitution)
+	}
+	return nil
+}
+
+// collisionPasses is how many times overlaps are resolved per step. Each pass
+// resolves every contact at once, so a particle pressed from several sides
+// needs more than one to settle.
+const collisionPasses = 2
+
+// radius is the collision radius of particles of type t: half their size.
+func (w *World) radius(t uint16) float64 {
+	return float64(w.Properties[t].Size) / 2
+}
+
+// collideParticles treats the particles as hard discs, pushing apart any that
+// overlap and bouncing those that are moving together. Every particle works
+// out its own share of each contact from the particles as they were at the
+// start of the pass, so the passes run in parallel and need no randomness.
+func (w *World) collideParticles() {
+	restitution := 1.
+	switch settings.Collisions {
+	case "none":
+		return
+	case "elastic":
+	case "inelastic":
+		restitution = settings.CollisionRestitution
+	default:
+		log.Fatal("Unknown collision mode.")
+	}
+
+	// a grid that reaches as far as the largest pair of discs in use
+	reach := 0.
+	for t := 0; t < settings.Types; t++ {
+		reach = maxFloat(reach, 2*w.radius(uint16(t)))
+	}
+	if reach == 0 {
+		return
+	}
+	if w.collideGrid == nil || w.collideGrid.CellSize != reach || w.collideGrid.Boundary != w.boundary {
+		w.collideGrid = NewGrid(settings.Width, settings.Height, reach, w.boundary)
+	}
+
+	for pass := 0; pass < collisionPasses; pass++ {
+		ps := w.Particles
+		n := len(ps)
+		if cap(w.back) < n {
+			w.back = make([]Particle, n)
+		}
+		if cap(w.alive) < n {
+			w.alive = make([]bool, n)
+		}
+		w.back, w.alive = w.back[:n], w.alive[:n]
+		w.collideGrid.Build(ps)
+		workers.run(n, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				w.back[i] = ps[i]
+				w.alive[i] = w.resolve(ps, i, restitution, &w.back[i])
+			}
+		})
+		if w.boundary.Absorb {
+			w.dropAbsorbed()
+		}
+		w.Particles, w.back = w.back, w.Particles
+	}
+}
+
+// resolve moves and bounces out, a copy of particle i, off every particle it
+// overlaps. It returns false if that pushed the particle into an absorbing
+// wall.
+func (w *World) resolve(ps []Particle, i int, restitution float64, out *Particle) bool {
+	p := ps[i]
+	r := w.radius(p.Type)
+	inv := 1 / w.Properties[p.Type].Mass
+	moved := false
+	w.collideGrid.Neighbours(i, func(j int) {
+		other := ps[j]
+		dx, dy, d := w.boundary.displacement(p.X, p.Y, other.X, other.Y, Euclidean)
+		overlap := r + w.radius(other.Type) - d
+		if overlap <= 0 {
+			return
+		}
+		// the normal from p to the other particle; coincident particles
+		// are split along x, in index order
+		nx, ny := 1., 0.
+		if d > 0 {
+			nx, ny = dx/d, dy/d
+		}
+		if j < i && d == 0 {
+			nx = -1
+		}
+		share := inv / (inv + 1/w.Properties[other.Type].Mass)
+
+		out.X -= nx * overlap * share
+		out.Y -= ny * overlap * share
+		moved = true
+
+		// only particles moving together bounce
+		vn := (other.Velocity[0]-p.Velocity[0])*nx + (other.Velocity[1]-p.Velocity[1])*ny
+		if vn < 0 {
+			out.Velocity[0] += (1 + restitution) * vn * share * nx
+			out.Velocity[1] += (1 + restitution) * vn * share * ny
+		}
+	})
+	if !moved {
+		return true
+	}
+	if !w.boundary.confine(out) {
+		return false
+	}
+	w.collide(p, out)
+	return true
+}
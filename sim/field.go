@@ -0,0 +1,172 @@
+package sim
+
+import (
+	"fmt"
+	"life/settings"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Field is an acceleration that acts on every particle wherever it is, on top
+// of the pair forces. What the parameters mean depends on Kind:
+//
+//	gravity   a uniform pull of Strength towards Angle
+//	well      a pull of Strength towards X, Y, or a push when negative,
+//	          softened within Radius
+//	vortex    a swirl of Strength around X, Y, softened within Radius
+//	shear     a flow towards Angle growing by Strength per half the world
+//	          height away from the line through X, Y
+//	coriolis  the Coriolis force of a frame rotating at Strength
+//
+// Angles are in degrees, clockwise from the positive x axis as the screen is
+// upside down, so 90 is down.
+type Field struct {
+	Kind     string
+	X, Y     float64
+	Strength float64
+	Angle    float64
+	Radius   float64
+}
+
+// FieldNames lists the kinds of field, in a stable order.
+var FieldNames = []string{"gravity", "well", "vortex", "shear", "coriolis"}
+
+// fieldOptions are the parameters each kind of field uses, by their names in
+// ParseField.
+var fieldOptions = map[string][]string{
+	"gravity":  {"s", "a"},
+	"well":     {"x", "y", "s", "r"},
+	"vortex":   {"x", "y", "s", "r"},
+	"shear":    {"x", "y", "s", "a"},
+	"coriolis": {"s"},
+}
+
+// DefaultField returns a field of the given kind with parameters that have a
+// visible but gentle effect, centred in the world.
+func DefaultField(kind string) Field {
+	f := Field{Kind: kind, X: settings.Width / 2, Y: settings.Height / 2}
+	switch kind {
+	case "gravity":
+		f.Strength, f.Angle = .05, 90
+	case "well":
+		f.Strength, f.Radius = .5, 50
+	case "vortex":
+		f.Strength, f.Radius = .5, 100
+	case "shear":
+		f.Strength = .1
+	case "coriolis":
+		f.Strength = .01
+	}
+	return f
+}
+
+// ParseField reads a field written as its kind and options, e.g.
+//
+//	gravity s=0.05 a=90
+//	well x=600 y=400 s=-1 r=50
+//
+// where x and y are the position, s the strength, a the angle and r the
+// radius. Options that are left out keep their values from DefaultField.
+func ParseField(s string) (Field, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Field{}, fmt.Errorf("empty field")
+	}
+	f := DefaultField(fields[0])
+	if err := f.validate(); err != nil {
+		return f, err
+	}
+
+	params := f.params()
+	for _, opt := range fields[1:] {
+		key, value, _ := strings.Cut(opt, "=")
+		v, ok := params[key]
+		if !ok {
+			return f, fmt.Errorf("unknown option %q for %s", key, f.Kind)
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return f, fmt.Errorf("bad %s %q", key, value)
+		}
+		*v = n
+	}
+	return f, f.validate()
+}
+
+// params maps the names of the options the kind of f uses to its fields.
+func (f *Field) params() map[string]*float64 {
+	all := map[string]*float64{"x": &f.X, "y": &f.Y, "s": &f.Strength, "a": &f.Angle, "r": &f.Radius}
+	params := map[string]*float64{}
+	for _, key := range fieldOptions[f.Kind] {
+		params[key] = all[key]
+	}
+	return params
+}
+
+func (f Field) String() string {
+	s := f.Kind
+	params := f.params()
+	for _, key := range fieldOptions[f.Kind] {
+		s += fmt.Sprintf(" %s=%g", key, *params[key])
+	}
+	return s
+}
+
+func (f Field) validate() error {
+	if _, ok := fieldOptions[f.Kind]; !ok {
+		return fmt.Errorf("unknown field %q", f.Kind)
+	}
+	if (f.Kind == "well" || f.Kind == "vortex") && f.Radius <= 0 {
+		return fmt.Errorf("%s with radius %g, want more than 0", f.Kind, f.Radius)
+	}
+	return nil
+}
+
+// acceleration returns the acceleration the field gives p.
+func (f Field) acceleration(p Particle, b Boundary, m Metric) [2]float64 {
+	switch f.Kind {
+	case "gravity":
+		sin, cos := math.Sincos(f.Angle * math.Pi / 180)
+		return [2]float64{f.Strength * cos, f.Strength * sin}
+	case "well", "vortex":
+		// Plummer softening: the pull peaks at half the strength a radius
+		// away and falls off with distance outside it
+		dx, dy, d := b.displacement(p.X, p.Y, f.X, f.Y, m)
+		s := f.Strength * f.Radius / (d*d + f.Radius*f.Radius)
+		if f.Kind == "vortex" {
+			return [2]float64{-dy * s, dx * s}
+		}
+		return [2]float64{dx * s, dy * s}
+	case "shear":
+		sin, cos := math.Sincos(f.Angle * math.Pi / 180)
+		dx, dy, _ := b.displacement(f.X, f.Y, p.X, p.Y, m)
+		// distance from the line, across the direction of flow
+		across := dy*cos - dx*sin
+		s := f.Strength * across / (settings.Height / 2)
+		return [2]float64{s * cos, s * sin}
+	case "coriolis":
+		return [2]float64{2 * f.Strength * p.Velocity[1], -2 * f.Strength * p.Velocity[0]}
+	}
+	return [2]float64{}
+}
+
+// AddField adds f after the other fields.
+func (w *World) AddField(f Field) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	w.Fields = append(w.Fields, f)
+	return nil
+}
+
+// fieldAcceleration sums the acceleration every field gives p.
+func (w *World) fieldAcceleration(p Particle) [2]float64 {
+	var a [2]float64
+	for _, f := range w.Fields {
+		fa := f.acceleration(p, w.boundary, w.metric)
+		a[0] += fa[0]
+		a[1] += fa[1]
+	}
+	return a
+}
@@ -0,0 +1,81 @@
+package sim
+
+import (
+	"life/attract"
+	"life/settings"
+	"log"
+)
+
+// accelerate adds the acceleration a particle of type t feels towards one of
+// type ot to a. The other particle is d away along dx, dy; dividing by d makes
+// the direction a unit step in the world's metric.
+func accelerate(a *[2]float64, dx, dy, d float64, t, ot uint16, f attract.AttractionFunction) {
+	v := f(d, t, ot)
+	a[0] += dx / d * v
+	a[1] += dy / d * v
+}
+
+// accelerations computes the acceleration of every particle in ps from the
+// pair forces and its mass, plus the fields, writing them to out. ps is only
+// read, so it can be any stage of an integrator rather than the world's own
+// particles.
+func (w *World) accelerations(ps []Particle, out [][2]float64) {
+	var force func(ps []Particle, i int) [2]float64
+	switch settings.NeighbourSearch {
+	case "grid":
+		// one cutoff for every pair, the largest interaction radius in
+		// use, so pairs of types with smaller radii still visit some
+		// particles out of their reach; the kernels return 0 for those
+		cutoff := attract.LargestRadius(settings.Types)
+		if w.grid == nil || w.grid.CellSize != cutoff || w.grid.Boundary != w.boundary {
+			w.grid = NewGrid(settings.Width, settings.Height, cutoff, w.boundary)
+		}
+		w.grid.Build(ps)
+		force = w.gridForce
+	case "all":
+		force = w.allPairsForce
+	default:
+		log.Fatal("Unknown neighbour search.")
+	}
+
+	workers.run(len(ps), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			a := force(ps, i)
+			b := w.bondForce(ps, i)
+			a[0] += b[0]
+			a[1] += b[1]
+			m := w.Properties[ps[i].Type].Mass
+			f := w.fieldAcceleration(ps[i])
+			out[i] = [2]float64{a[0]/m + f[0], a[1]/m + f[1]}
+		}
+	})
+}
+
+// gridForce only visits particles in neighbouring cells. The kernels cut off
+// any pair further apart than their interaction radius.
+func (w *World) gridForce(ps []Particle, i int) [2]float64 {
+	var a [2]float64
+	p := ps[i]
+	kernels := w.Attractors[p.Type]
+	w.grid.Neighbours(i, func(j int) {
+		other := ps[j]
+		dx, dy, d := w.boundary.displacement(p.X, p.Y, other.X, other.Y, w.metric)
+		accelerate(&a, dx, dy, d, p.Type, other.Type, kernels[other.Type])
+	})
+	return a
+}
+
+// allPairsForce is the exhaustive O(n²) path.
+func (w *World) allPairsForce(ps []Particle, i int) [2]float64 {
+	var a [2]float64
+	p := ps[i]
+	kernels := w.Attractors[p.Type]
+	for j, other := range ps {
+		if i == j {
+			continue
+		}
+		dx, dy, d := w.boundary.displacement(p.X, p.Y, other.X, other.Y, w.metric)
+		accelerate(&a, dx, dy, d, p.Type, other.Type, kernels[other.Type])
+	}
+	return a
+}
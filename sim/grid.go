@@ -0,0 +1,196 @@
+package sim
+
+import "math"
+
+// Grid is a uniform cell list over the world. Cells are at least CellSize/Ring
+// wide, so every particle within CellSize of another (in any of the metrics)
+// lies in the block of cells Ring either side of its own. Cells wrap around
+// the edges of the world the same way the Boundary does.
+type Grid struct {
+	CellSize       float64
+	Boundary       Boundary
+	Width, Height  float64
+	Cols, Rows     int
+	CellW, CellH   float64
+	Ring           int
+	start, members []int
+	x, y           []float64
+	cells, next    []int // scratch space for Build
+}
+
+// maxGridSide caps the number of cells along each side of a grid, so that a
+// tiny or zero cell size does not allocate millions of cells. Capping only
+// makes cells wider, which keeps every neighbour within the search block.
+const maxGridSide = 256
+
+// gridRing is how many cells either side of its own a particle's neighbours
+// are searched in. Narrower cells fit the searched block closer to the
+// CellSize around a particle: with cells a quarter of CellSize wide it is 9/4
+// CellSize across rather than 3, a little over half the area, so far fewer
+// pairs out of reach are visited. Past that the extra cells cost about as
+// much as they save.
+const gridRing = 4
+
+// NewGrid returns a grid whose search block around a particle covers every
+// point within cellSize of it. When the world is too small for ring cells
+// either side to be distinct, the ring shrinks, down to a 3x3 block.
+func NewGrid(width, height, cellSize float64, b Boundary) *Grid {
+	ring := gridRing
+	cols, rows := gridSide(width, cellSize/float64(ring)), gridSide(height, cellSize/float64(ring))
+	for ring > 1 && (cols < 2*ring+1 || rows < 2*ring+1) {
+		ring--
+		cols, rows = gridSide(width, cellSize/float64(ring)), gridSide(height, cellSize/float64(ring))
+	}
+	return &Grid{
+		CellSize: cellSize,
+		Boundary: b,
+		Width:    width,
+		Height:   height,
+		Cols:     cols,
+		Rows:     rows,
+		CellW:    width / float64(cols),
+		CellH:    height / float64(rows),
+		Ring:     ring,
+		start:    make([]int, cols*rows+1),
+	}
+}
+
+// gridSide returns how many cells at least cellSize wide fit across size,
+// between 1 and maxGridSide.
+func gridSide(size, cellSize float64) int {
+	n := math.Floor(size / cellSize)
+	if !(n >= 1) {
+		// also catches a NaN cell size
+		return 1
+	}
+	return int(math.Min(n, maxGridSide))
+}
+
+func wrap(v, size float64) float64 {
+	v = math.Mod(v, size)
+	if v < 0 {
+		v += size
+	}
+	return v
+}
+
+func clamp(v, size float64) float64 {
+	return math.Max(0, math.Min(size, v))
+}
+
+func (g *Grid) cell(x, y float64) (int, int) {
+	return bin(x/g.CellW, g.Cols), bin(y/g.CellH, g.Rows)
+}
+
+// bin returns the cell v falls in out of n, putting anything outside them,
+// including NaN from a particle that has blown up, in the nearest end cell.
+func bin(v float64, n int) int {
+	if !(v >= 0) {
+		return 0
+	}
+	// also guards against rounding at the far edge
+	if v >= float64(n) {
+		return n - 1
+	}
+	return int(v)
+}
+
+// Build sorts the particles into cells. It must be called whenever the
+// particles have moved.
+func (g *Grid) Build(particles []Particle) {
+	if cap(g.members) < len(particles) {
+		g.members = make([]int, len(particles))
+		g.x = make([]float64, len(particles))
+		g.y = make([]float64, len(particles))
+		g.cells = make([]int, len(particles))
+	}
+	g.members = g.members[:len(particles)]
+	g.x = g.x[:len(particles)]
+	g.y = g.y[:len(particles)]
+	g.cells = g.cells[:len(particles)]
+	for i := range g.start {
+		g.start[i] = 0
+	}
+
+	// counting sort: count per cell, prefix sum, then place
+	cells := g.cells
+	for i, p := range particles {
+		g.x[i], g.y[i] = clamp(p.X, g.Width), clamp(p.Y, g.Height)
+		if g.Boundary.WrapX {
+			g.x[i] = wrap(p.X, g.Width)
+		}
+		if g.Boundary.WrapY {
+			g.y[i] = wrap(p.Y, g.Height)
+		}
+		cx, cy := g.cell(g.x[i], g.y[i])
+		cells[i] = cy*g.Cols + cx
+		g.start[cells[i]+1]++
+	}
+	for c := 1; c < len(g.start); c++ {
+		g.start[c] += g.start[c-1]
+	}
+	g.next = append(g.next[:0], g.start[:len(g.start)-1]...)
+	next := g.next
+	for i, c := range cells {
+		g.members[next[c]] = i
+		next[c]++
+	}
+}
+
+// Neighbours calls fn for every particle in the block of cells Ring either
+// side of particle i's, other than i itself. Cells past a wall are skipped,
+// and when the grid is less than three cells across, cells that wrap onto
+// each other are only visited once. NewGrid only keeps a wider ring when its
+// cells are all distinct.
+func (g *Grid) Neighbours(i int, fn func(j int)) {
+	cx, cy := g.cell(g.x[i], g.y[i])
+
+	var visited [9]int
+	n := 0
+	for dy := -g.Ring; dy <= g.Ring; dy++ {
+		row := cy + dy
+		crossed := row < 0 || row >= g.Rows
+		if crossed {
+			if !g.Boundary.WrapY {
+				continue
+			}
+			row = (row + g.Rows) % g.Rows
+		}
+		for dx := -g.Ring; dx <= g.Ring; dx++ {
+			col := cx + dx
+			if col < 0 || col >= g.Cols {
+				if !g.Boundary.WrapX {
+					continue
+				}
+				col = (col + g.Cols) % g.Cols
+			}
+			if crossed && g.Boundary.FlipX {
+				col = g.Cols - 1 - col
+			}
+
+			c := row*g.Cols + col
+			if g.Ring == 1 {
+				if seen(visited[:n], c) {
+					continue
+				}
+				visited[n] = c
+				n++
+			}
+
+			for _, j := range g.members[g.start[c]:g.start[c+1]] {
+				if j != i {
+					fn(j)
+				}
+			}
+		}
+	}
+}
+
+func seen(cells []int, c int) bool {
+	for _, v := range cells {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package sim
+
+import (
+	"fmt"
+	"life/settings"
+	"math"
+)
+
+// IntegratorNames lists the integrators Step understands, in a stable order.
+var IntegratorNames = []string{"euler", "verlet", "rk4"}
+
+// CheckTimestep returns an error unless settings.DT and settings.StepRate are
+// finite and positive.
+func CheckTimestep() error {
+	if !(settings.DT > 0) || math.IsInf(settings.DT, 1) {
+		return fmt.Errorf("dt %g, want more than 0", settings.DT)
+	}
+	if !(settings.StepRate > 0) || math.IsInf(settings.StepRate, 1) {
+		return fmt.Errorf("step rate %g, want more than 0", settings.StepRate)
+	}
+	return nil
+}
+
+// Positions move by settings.Speed times the velocity. Friction is left to
+// Step, which applies it after the integrator as an exponential decay so that
+// it scales correctly with dt.
+
+// euler is semi-implicit (symplectic) Euler: the velocity is updated first and
+// the position moves with the new velocity.
+func (w *World) euler(dt float64) {
+	w.accelerations(w.Particles, w.acc)
+	workers.run(len(w.Particles), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			p := w.Particles[i]
+			p.Acceleration = w.acc[i]
+			p.Velocity[0] += p.Acceleration[0] * dt
+			p.Velocity[1] += p.Acceleration[1] * dt
+			p.UpdatePosition(dt)
+			w.back[i] = p
+		}
+	})
+}
+
+// verlet is velocity Verlet. It reuses the acceleration stored on each
+// particle by the previous step, so it costs one force evaluation per step.
+func (w *World) verlet(dt float64) {
+	workers.run(len(w.Particles), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			p := w.Particles[i]
+			p.X += settings.Speed * (p.Velocity[0]*dt + .5*p.Acceleration[0]*dt*dt)
+			p.Y += settings.Speed * (p.Velocity[1]*dt + .5*p.Acceleration[1]*dt*dt)
+			w.back[i] = p
+		}
+	})
+
+	w.accelerations(w.back, w.acc)
+	workers.run(len(w.back), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			p := &w.back[i]
+			p.Velocity[0] += .5 * (p.Acceleration[0] + w.acc[i][0]) * dt
+			p.Velocity[1] += .5 * (p.Acceleration[1] + w.acc[i][1]) * dt
+			p.Acceleration = w.acc[i]
+		}
+	})
+}
+
+// rk4 is the classic fourth order Runge-Kutta method over position and
+// velocity. It evaluates the forces four times per step.
+func (w *World) rk4(dt float64) {
+	n := len(w.Particles)
+	if cap(w.stage) < n {
+		w.stage = make([]Particle, n)
+		w.sum = make([][4]float64, n)
+	}
+	w.stage, w.sum = w.stage[:n], w.sum[:n]
+	copy(w.stage, w.Particles)
+
+	// stage k is evaluated at the start plus dt*offsets[k] of the previous
+	// derivative, and contributes weights[k] of its own to the result
+	offsets := [4]float64{0, dt / 2, dt / 2, dt}
+	weights := [4]float64{1, 2, 2, 1}
+	for k := 0; k < 4; k++ {
+		w.accelerations(w.stage, w.acc)
+		workers.run(n, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				s := &w.stage[i]
+				// derivative of (x, y, vx, vy) at this stage
+				deriv := [4]float64{
+					settings.Speed * s.Velocity[0], settings.Speed * s.Velocity[1],
+					w.acc[i][0], w.acc[i][1],
+				}
+				if k == 0 {
+					w.sum[i] = [4]float64{}
+				}
+				for c := range deriv {
+					w.sum[i][c] += weights[k] * deriv[c]
+				}
+				if k == 3 {
+					continue
+				}
+
+				p := w.Particles[i]
+				step := offsets[k+1]
+				s.X = p.X + step*deriv[0]
+				s.Y = p.Y + step*deriv[1]
+				s.Velocity[0] = p.Velocity[0] + step*deriv[2]
+				s.Velocity[1] = p.Velocity[1] + step*deriv[3]
+			}
+		})
+	}
+
+	workers.run(n, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			p := w.Particles[i]
+			p.X += dt / 6 * w.sum[i][0]
+			p.Y += dt / 6 * w.sum[i][1]
+			p.Velocity[0] += dt / 6 * w.sum[i][2]
+			p.Velocity[1] += dt / 6 * w.sum[i][3]
+			p.Acceleration = w.acc[i]
+			w.back[i] = p
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package sim
+
+import (
+	"life/settings"
+	"log"
+	"math"
+)
+
+// Metric measures the length of a displacement dx, dy.
+type Metric func(dx, dy float64) float64
+
+func Euclidean(dx, dy float64) float64 {
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// Manhattan is cheaper than Euclidean and was the only metric for a long time.
+func Manhattan(dx, dy float64) float64 {
+	return math.Abs(dx) + math.Abs(dy)
+}
+
+func Chebyshev(dx, dy float64) float64 {
+	return math.Max(math.Abs(dx), math.Abs(dy))
+}
+
+var Metrics = map[string]Metric{
+	"euclidean": Euclidean,
+	"manhattan": Manhattan,
+	"chebyshev": Chebyshev,
+}
+
+func currentMetric() Metric {
+	m, ok := Metrics[settings.Metric]
+	if !ok {
+		log.Fatal("Unknown metric.")
+	}
+	return m
+}
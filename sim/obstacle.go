@@ -0,0 +1,286 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/png" // masks are PNG files
+	"life/settings"
+	"math"
+	"os"
+)
+
+// Obstacle is a static shape particles bounce off: a "segment" between two
+// points, a "circle" around its one point, or a solid "polygon" with its
+// points as vertices.
+type Obstacle struct {
+	Kind   string
+	Points [][2]float64
+	Radius float64 `json:",omitempty"`
+}
+
+// skin is how far outside an obstacle a particle is put back, so it does not
+// hit the same surface again straight away.
+const skin = 1e-3
+
+func (o Obstacle) validate() error {
+	want := map[string]int{"segment": 2, "circle": 1, "polygon": 3}
+	n, ok := want[o.Kind]
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown obstacle %q", o.Kind)
+	case o.Kind == "polygon" && len(o.Points) < n:
+		return fmt.Errorf("polygon with %d points, want at least %d", len(o.Points), n)
+	case o.Kind != "polygon" && len(o.Points) != n:
+		return fmt.Errorf("%s with %d points, want %d", o.Kind, len(o.Points), n)
+	case o.Kind == "circle" && o.Radius <= 0:
+		return fmt.Errorf("circle with radius %g", o.Radius)
+	}
+	return nil
+}
+
+// Edges returns the line segments that make up the outline of the obstacle.
+// A circle has none.
+func (o Obstacle) Edges() [][2][2]float64 {
+	switch o.Kind {
+	case "segment":
+		return [][2][2]float64{{o.Points[0], o.Points[1]}}
+	case "polygon":
+		edges := make([][2][2]float64, len(o.Points))
+		for i := range o.Points {
+			edges[i] = [2][2]float64{o.Points[i], o.Points[(i+1)%len(o.Points)]}
+		}
+		return edges
+	}
+	return nil
+}
+
+// Inside reports whether x, y is within the solid part of the obstacle.
+func (o Obstacle) Inside(x, y float64) bool {
+	switch o.Kind {
+	case "circle":
+		return math.Hypot(x-o.Points[0][0], y-o.Points[0][1]) < o.Radius
+	case "polygon":
+		// even-odd rule
+		inside := false
+		for _, e := range o.Edges() {
+			a, b := e[0], e[1]
+			if (a[1] > y) != (b[1] > y) && x < a[0]+(y-a[1])/(b[1]-a[1])*(b[0]-a[0]) {
+				inside = !inside
+			}
+		}
+		return inside
+	}
+	return false
+}
+
+// collide moves p back out of the obstacle if it went through an edge or
+// ended up inside, given where it was before the step, and bounces it off.
+func (o Obstacle) collide(old Particle, p *Particle) {
+	// paths that wrapped around the world do not cross anything in between
+	if math.Abs(p.X-old.X) < settings.Width/2 && math.Abs(p.Y-old.Y) < settings.Height/2 {
+		// the first edge along the path, which a fast particle may have
+		// crossed before others of a thin obstacle
+		first, hit, n := math.Inf(1), [2]float64{}, [2]float64{}
+		for _, e := range o.Edges() {
+			if h, en, t, ok := crossing(old.X, old.Y, p.X, p.Y, e[0], e[1]); ok && t < first {
+				first, hit, n = t, h, en
+			}
+		}
+		if !math.IsInf(first, 1) {
+			p.X, p.Y = hit[0]+n[0]*skin, hit[1]+n[1]*skin
+			bounce(p, n)
+		}
+	}
+	if !o.Inside(p.X, p.Y) {
+		return
+	}
+
+	// push out through the nearest point of the surface
+	var q [2]float64
+	if o.Kind == "circle" {
+		c := o.Points[0]
+		d := math.Hypot(p.X-c[0], p.Y-c[1])
+		if d == 0 {
+			d, p.X = 1, p.X+1
+		}
+		q = [2]float64{c[0] + (p.X-c[0])/d*o.Radius, c[1] + (p.Y-c[1])/d*o.Radius}
+	} else {
+		best := math.Inf(1)
+		for _, e := range o.Edges() {
+			if c := nearest(p.X, p.Y, e[0], e[1]); math.Hypot(c[0]-p.X, c[1]-p.Y) < best {
+				q, best = c, math.Hypot(c[0]-p.X, c[1]-p.Y)
+			}
+		}
+	}
+	n := [2]float64{q[0] - p.X, q[1] - p.Y}
+	if d := math.Hypot(n[0], n[1]); d > 0 {
+		n = [2]float64{n[0] / d, n[1] / d}
+	}
+	p.X, p.Y = q[0]+n[0]*skin, q[1]+n[1]*skin
+	bounce(p, n)
+}
+
+// crossing finds where the path from x0, y0 to x1, y1 crosses the segment from
+// a to b, along with the normal of the segment on the side the path came from
+// and how far along the path, from 0 to 1, the crossing is.
+func crossing(x0, y0, x1, y1 float64, a, b [2]float64) ([2]float64, [2]float64, float64, bool) {
+	dx, dy := x1-x0, y1-y0
+	ex, ey := b[0]-a[0], b[1]-a[1]
+	den := dx*ey - dy*ex
+	if den == 0 {
+		return [2]float64{}, [2]float64{}, 0, false
+	}
+	t := ((a[0]-x0)*ey - (a[1]-y0)*ex) / den
+	u := ((a[0]-x0)*dy - (a[1]-y0)*dx) / den
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return [2]float64{}, [2]float64{}, 0, false
+	}
+
+	l := math.Hypot(ex, ey)
+	n := [2]float64{-ey / l, ex / l}
+	if n[0]*dx+n[1]*dy > 0 {
+		n = [2]float64{-n[0], -n[1]}
+	}
+	return [2]float64{x0 + t*dx, y0 + t*dy}, n, t, true
+}
+
+// nearest returns the point of the segment from a to b closest to x, y.
+func nearest(x, y float64, a, b [2]float64) [2]float64 {
+	ex, ey := b[0]-a[0], b[1]-a[1]
+	t := 0.
+	if l := ex*ex + ey*ey; l > 0 {
+		t = math.Max(0, math.Min(1, ((x-a[0])*ex+(y-a[1])*ey)/l))
+	}
+	return [2]float64{a[0] + t*ex, a[1] + t*ey}
+}
+
+// bounce reflects the part of the velocity going against the surface normal n,
+// losing speed according to settings.Restitution.
+func bounce(p *Particle, n [2]float64) {
+	if v := p.Velocity[0]*n[0] + p.Velocity[1]*n[1]; v < 0 {
+		p.Velocity[0] -= (1 + settings.Restitution) * v * n[0]
+		p.Velocity[1] -= (1 + settings.Restitution) * v * n[1]
+	}
+}
+
+// Mask is a bitmap of solid regions stretched over the whole world.
+type Mask struct {
+	Width, Height int
+	// Bits holds a bit per pixel, row by row, set where the mask is solid.
+	Bits []byte
+}
+
+// LoadMask reads a PNG and makes every pixel darker than mid grey, and not
+// transparent, solid.
+func LoadMask(path string) (*Mask, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	m := &Mask{Width: b.Dx(), Height: b.Dy(), Bits: make([]byte, (b.Dx()*b.Dy()+7)/8)}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			if a > 0x7fff && (r+g+bl)/3 < 0x7fff {
+				i := y*m.Width + x
+				m.Bits[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Solid reports whether the world position x, y is in a solid region.
+func (m *Mask) Solid(x, y float64) bool {
+	return m.At(int(x*float64(m.Width)/settings.Width), int(y*float64(m.Height)/settings.Height))
+}
+
+// At reports whether pixel x, y of the mask is solid.
+func (m *Mask) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return false
+	}
+	i := y*m.Width + x
+	return m.Bits[i/8]&(1<<(i%8)) != 0
+}
+
+// collide undoes the part of the step that took p into a solid region and
+// bounces it off along that axis. A particle that was already inside, e.g.
+// because the mask was loaded over it, moves freely until it gets out.
+func (m *Mask) collide(old Particle, p *Particle) {
+	if !m.Solid(p.X, p.Y) || m.Solid(old.X, old.Y) {
+		return
+	}
+	e := settings.Restitution
+	switch {
+	case !m.Solid(old.X, p.Y):
+		p.X = old.X
+		p.Velocity[0] *= -e
+	case !m.Solid(p.X, old.Y):
+		p.Y = old.Y
+		p.Velocity[1] *= -e
+	default:
+		p.X, p.Y = old.X, old.Y
+		p.Velocity[0] *= -e
+		p.Velocity[1] *= -e
+	}
+}
+
+// Blocked reports whether x, y is inside any obstacle or the mask.
+func (w *World) Blocked(x, y float64) bool {
+	if w.Mask != nil && w.Mask.Solid(x, y) {
+		return true
+	}
+	for _, o := range w.Obstacles {
+		if o.Inside(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// collide keeps p out of the obstacles after a step, given where it was
+// before.
+func (w *World) collide(old Particle, p *Particle) {
+	for _, o := range w.Obstacles {
+		o.collide(old, p)
+	}
+	if w.Mask != nil {
+		w.Mask.collide(old, p)
+	}
+}
+
+// AddObstacle adds o to the world.
+func (w *World) AddObstacle(o Obstacle) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	w.Obstacles = append(w.Obstacles, o)
+	return nil
+}
+
+// LoadObstacles reads a JSON list of obstacles and adds them to the world.
+func (w *World) LoadObstacles(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var obstacles []Obstacle
+	if err := json.Unmarshal(data, &obstacles); err != nil {
+		return err
+	}
+	for i, o := range obstacles {
+		if err := w.AddObstacle(o); err != nil {
+			return fmt.Errorf("obstacle %d: %v", i, err)
+		}
+	}
+	return nil
+}
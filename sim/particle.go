@@ -0,0 +1,28 @@
+package sim
+
+import (
+	"life/attract"
+	"life/settings"
+)
+
+type Particle struct {
+	X, Y     float64
+	Velocity [2]float64
+	// Acceleration from the pair forces at the end of the last step, kept for
+	// the Verlet integrator.
+	Acceleration [2]float64
+	Type         uint16
+}
+
+// UpdateVelocity gives p an impulse along the displacement dx, dy towards a
+// particle of type ot, d away in the world's metric.
+func (p *Particle) UpdateVelocity(dx, dy, d float64, ot uint16, attract attract.AttractionFunction) {
+	accelerate(&p.Velocity, dx, dy, d, p.Type, ot, attract)
+}
+
+// UpdatePosition moves p by its velocity over dt. The caller applies friction
+// and the boundary afterwards.
+func (p *Particle) UpdatePosition(dt float64) {
+	p.X += p.Velocity[0] * settings.Speed * dt
+	p.Y += p.Velocity[1] * settings.Speed * dt
+}
@@ -0,0 +1,55 @@
+package sim
+
+import (
+	"runtime"
+	"sync"
+)
+
+// pool is a fixed set of worker goroutines that split a range of indices
+// between them. It is shared by every world.
+type pool struct {
+	size int
+	jobs chan job
+}
+
+type job struct {
+	lo, hi int
+	fn     func(lo, hi int)
+	wg     *sync.WaitGroup
+}
+
+var workers = newPool(runtime.GOMAXPROCS(0))
+
+func newPool(size int) *pool {
+	p := &pool{size: size, jobs: make(chan job)}
+	for i := 0; i < size; i++ {
+		go func() {
+			for j := range p.jobs {
+				j.fn(j.lo, j.hi)
+				j.wg.Done()
+			}
+		}()
+	}
+	return p
+}
+
+// run calls fn over [0, n) in chunks and waits for every chunk to finish.
+// There are a few chunks per worker so an expensive region of the world does
+// not leave the other workers idle.
+func (p *pool) run(n int, fn func(lo, hi int)) {
+	chunk := n / (p.size * 4)
+	if chunk < 64 {
+		chunk = 64
+	}
+
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+		wg.Add(1)
+		p.jobs <- job{lo: lo, hi: hi, fn: fn, wg: &wg}
+	}
+	wg.Wait()
+}
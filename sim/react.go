@@ -0,0 +1,195 @@
+package sim
+
+import (
+	"fmt"
+	"life/settings"
+	"strconv"
+	"strings"
+)
+
+// Reaction changes the type of particles according to their neighbours. A
+// particle of type From that has at least Count particles of type Other within
+// Radius becomes type To with the given Probability each step. With a Count of
+// 1 this is "From meets Other", and with more it is "From is surrounded by
+// Other".
+type Reaction struct {
+	From, Other, To int
+	Count           int
+	Radius          float64
+	Probability     float64
+}
+
+// ParseReaction reads a reaction written as
+//
+//	A+B>C r=20 p=0.1
+//	A+3*B>C r=30
+//
+// where A, B and C are types, 3 the number of B needed, r the radius and p the
+// probability. The radius defaults to settings.RepelRadius doubled and the
+// probability to 1.
+func ParseReaction(s string) (Reaction, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Reaction{}, fmt.Errorf("empty reaction")
+	}
+	r := Reaction{Count: 1, Radius: 2 * settings.RepelRadius, Probability: 1}
+
+	lhs, to, ok := strings.Cut(fields[0], ">")
+	from, other, ok2 := strings.Cut(lhs, "+")
+	if !ok || !ok2 {
+		return r, fmt.Errorf("want A+B>C, got %q", fields[0])
+	}
+	if count, t, ok := strings.Cut(other, "*"); ok {
+		other = t
+		if err := parseInt(count, &r.Count, "count"); err != nil {
+			return r, err
+		}
+	}
+	for _, f := range []struct {
+		s string
+		v *int
+	}{{from, &r.From}, {other, &r.Other}, {to, &r.To}} {
+		if err := parseInt(f.s, f.v, "type"); err != nil {
+			return r, err
+		}
+	}
+
+	for _, opt := range fields[1:] {
+		key, value, _ := strings.Cut(opt, "=")
+		var err error
+		switch key {
+		case "r":
+			r.Radius, err = strconv.ParseFloat(value, 64)
+		case "p":
+			r.Probability, err = strconv.ParseFloat(value, 64)
+		default:
+			err = fmt.Errorf("unknown option %q", key)
+		}
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, r.validate(settings.MaxTypes)
+}
+
+func parseInt(s string, v *int, what string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("bad %s %q", what, s)
+	}
+	*v = n
+	return nil
+}
+
+func (r Reaction) String() string {
+	other := strconv.Itoa(r.Other)
+	if r.Count != 1 {
+		other = fmt.Sprintf("%d*%d", r.Count, r.Other)
+	}
+	return fmt.Sprintf("%d+%s>%d r=%g p=%g", r.From, other, r.To, r.Radius, r.Probability)
+}
+
+// validate checks the reaction only involves the first types types.
+func (r Reaction) validate(types int) error {
+	for _, t := range []int{r.From, r.Other, r.To} {
+		if t < 0 || t >= types {
+			return fmt.Errorf("type %d out of range, want 0 to %d", t, types-1)
+		}
+	}
+	if r.Count < 1 {
+		return fmt.Errorf("count %d, want at least 1", r.Count)
+	}
+	if r.Radius <= 0 {
+		return fmt.Errorf("radius %g, want more than 0", r.Radius)
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability %g, want 0 to 1", r.Probability)
+	}
+	return nil
+}
+
+// AddReaction adds r after the other reactions, as long as it only involves
+// active types.
+func (w *World) AddReaction(r Reaction) error {
+	if err := r.validate(settings.Types); err != nil {
+		return err
+	}
+	w.Reactions = append(w.Reactions, r)
+	return nil
+}
+
+// react applies the reactions once. Neighbours are counted in parallel from
+// the types as they were before the step, then the reactions fire in order,
+// at most one per particle, drawing from w.Rand in particle order so runs stay
+// reproducible.
+func (w *World) react() {
+	if len(w.Reactions) == 0 {
+		return
+	}
+
+	// a grid with cells as large as the largest reaction radius
+	reach := 0.
+	for _, r := range w.Reactions {
+		reach = maxFloat(reach, r.Radius)
+	}
+	if w.reactGrid == nil || w.reactGrid.CellSize != reach || w.reactGrid.Boundary != w.boundary {
+		w.reactGrid = NewGrid(settings.Width, settings.Height, reach, w.boundary)
+	}
+	ps := w.Particles
+	w.reactGrid.Build(ps)
+
+	rules := len(w.Reactions)
+	if cap(w.counts) < len(ps)*rules {
+		w.counts = make([]int32, len(ps)*rules)
+	}
+	counts := w.counts[:len(ps)*rules]
+	workers.run(len(ps), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			c := counts[i*rules : (i+1)*rules]
+			for k := range c {
+				c[k] = 0
+			}
+			p := ps[i]
+			w.reactGrid.Neighbours(i, func(j int) {
+				other := ps[j]
+				for k, r := range w.Reactions {
+					if int(p.Type) == r.From && int(other.Type) == r.Other {
+						if _, _, d := w.boundary.displacement(p.X, p.Y, other.X, other.Y, w.metric); d <= r.Radius {
+							c[k]++
+						}
+					}
+				}
+			})
+		}
+	})
+
+	for i := range ps {
+		for k, r := range w.Reactions {
+			if int(ps[i].Type) != r.From || int(counts[i*rules+k]) < r.Count {
+				continue
+			}
+			if r.Probability >= 1 || w.Rand.Float64() < r.Probability {
+				ps[i].Type = uint16(r.To)
+				break
+			}
+		}
+	}
+}
+
+func maxFloat(a, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// Population counts the particles of each active type.
+func (w *World) Population() []int {
+	counts := make([]int, settings.Types)
+	for _, p := range w.Particles {
+		if int(p.Type) < len(counts) {
+			counts[p.Type]++
+		}
+	}
+	return counts
+}
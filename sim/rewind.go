@@ -0,0 +1,118 @@
+package sim
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/gob"
+	"fmt"
+	"life/settings"
+)
+
+// Rewind is a ring buffer of compressed snapshots of a world, taken every
+// settings.RewindEvery ticks, oldest first. Once it is full each new snapshot
+// replaces the oldest, so it always holds the most recent history.
+type Rewind struct {
+	frames []frame
+	// start is the index of the oldest frame in frames, and n how many of
+	// them are in use
+	start, n int
+}
+
+type frame struct {
+	ticks int
+	data  []byte
+}
+
+// CheckRewind returns an error unless settings.RewindEvery and
+// settings.RewindFrames are at least 1.
+func CheckRewind() error {
+	if settings.RewindEvery < 1 {
+		return fmt.Errorf("rewind every %d ticks, want at least 1", settings.RewindEvery)
+	}
+	if settings.RewindFrames < 1 {
+		return fmt.Errorf("%d rewind frames, want at least 1", settings.RewindFrames)
+	}
+	return nil
+}
+
+// NewRewind returns an empty buffer that holds up to size snapshots.
+func NewRewind(size int) *Rewind {
+	return &Rewind{frames: make([]frame, size)}
+}
+
+// Len returns how many snapshots the buffer holds.
+func (r *Rewind) Len() int {
+	return r.n
+}
+
+// Ticks returns the tick the i-th oldest snapshot was taken at.
+func (r *Rewind) Ticks(i int) int {
+	return r.at(i).ticks
+}
+
+func (r *Rewind) at(i int) *frame {
+	return &r.frames[(r.start+i)%len(r.frames)]
+}
+
+// Record takes a snapshot of w if settings.RewindEvery ticks have passed
+// since the last one, or the world has gone back in time since, e.g. because
+// a snapshot was loaded.
+func (r *Rewind) Record(w *World) error {
+	if r.n > 0 {
+		if since := w.Ticks - r.at(r.n-1).ticks; since >= 0 && since < settings.RewindEvery {
+			return nil
+		}
+	}
+	return r.Push(w)
+}
+
+// Push takes a snapshot of w straight away.
+func (r *Rewind) Push(w *World) error {
+	if len(r.frames) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	z, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(z).Encode(w.Snapshot()); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+
+	if r.n == len(r.frames) {
+		r.start = (r.start + 1) % len(r.frames)
+		r.n--
+	}
+	*r.at(r.n) = frame{ticks: w.Ticks, data: buf.Bytes()}
+	r.n++
+	return nil
+}
+
+// Restore puts w back in the state of the i-th oldest snapshot.
+func (r *Rewind) Restore(w *World, i int) error {
+	if i < 0 || i >= r.n {
+		return fmt.Errorf("snapshot %d of %d", i, r.n)
+	}
+	var s Snapshot
+	z := flate.NewReader(bytes.NewReader(r.at(i).data))
+	defer z.Close()
+	if err := gob.NewDecoder(z).Decode(&s); err != nil {
+		return err
+	}
+	return w.Restore(s)
+}
+
+// Truncate drops every snapshot after the n oldest, e.g. to branch a new run
+// off from the n-th.
+func (r *Rewind) Truncate(n int) {
+	if n < 0 {
+		n = 0
+	}
+	for ; r.n > n; r.n-- {
+		*r.at(r.n - 1) = frame{}
+	}
+}
@@ -0,0 +1,207 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"os"
+)
+
+// Ruleset is everything that defines how a world behaves, as opposed to where
+// its particles are. Only the active types are stored.
+type Ruleset struct {
+	Types      int
+	Attraction [][]float64
+	Radius     [][]float64
+	Kernels    [][]string
+	// KernelParams holds the parameters of each pair's kernel, if it has
+	// any.
+	KernelParams [][][]float64 `json:",omitempty"`
+	// Expressions holds the source of the user defined kernels by name.
+	Expressions map[string]string
+	Properties  []TypeProperties
+	// Curve is the force curve of the "curve" kernel.
+	Curve attract.Curve
+	// Timeline holds the matrix keyframes, if any.
+	Timeline  Timeline
+	Reactions []Reaction
+	Fields    []Field
+	BondRules []BondRule
+}
+
+// Ruleset captures the current rules of the world.
+func (w *World) Ruleset() Ruleset {
+	r := Ruleset{
+		Types:        settings.Types,
+		Attraction:   make([][]float64, settings.Types),
+		Radius:       make([][]float64, settings.Types),
+		Kernels:      make([][]string, settings.Types),
+		KernelParams: make([][][]float64, settings.Types),
+		Expressions:  map[string]string{},
+		Properties:   append([]TypeProperties(nil), w.Properties[:settings.Types]...),
+		Curve: attract.Curve{
+			Points: append([][2]float64(nil), attract.ForceCurve.Points...),
+			Smooth: attract.ForceCurve.Smooth,
+		},
+		Timeline:  w.Timeline,
+		Reactions: append([]Reaction(nil), w.Reactions...),
+		Fields:    append([]Field(nil), w.Fields...),
+		BondRules: append([]BondRule(nil), w.BondRules...),
+	}
+	for i := 0; i < settings.Types; i++ {
+		r.Attraction[i] = append([]float64(nil), attract.AttractionMatrix[i][:settings.Types]...)
+		r.Radius[i] = append([]float64(nil), attract.RadiusMatrix[i][:settings.Types]...)
+		r.Kernels[i] = append([]string(nil), w.Kernels[i][:settings.Types]...)
+		r.KernelParams[i] = append([][]float64(nil), w.Params[i][:settings.Types]...)
+		for _, name := range r.Kernels[i] {
+			if src, ok := attract.Expressions[name]; ok {
+				r.Expressions[name] = src
+			}
+		}
+	}
+	return r
+}
+
+// ApplyRuleset replaces the rules of the world. Types beyond those in the
+// ruleset are left as they were.
+func (w *World) ApplyRuleset(r Ruleset) error {
+	for name, src := range r.Expressions {
+		if err := attract.RegisterExpression(name, src); err != nil {
+			return fmt.Errorf("kernel %q: %v", name, err)
+		}
+		w.RebuildKernel(name)
+	}
+	if err := r.checkSize(); err != nil {
+		return err
+	}
+	for i, row := range r.Kernels {
+		for j, name := range row {
+			kernel, ok := attract.Kernels[name]
+			if !ok {
+				return fmt.Errorf("unknown kernel %q", name)
+			}
+			if params := r.params(i, j); params != nil && len(params) != kernel.Params {
+				return fmt.Errorf("kernel %q takes %d parameters, got %d", name, kernel.Params, len(params))
+			}
+		}
+	}
+
+	for t, p := range r.Properties {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("type %d: %v", t, err)
+		}
+	}
+	if err := r.Timeline.validate(); err != nil {
+		return err
+	}
+	for _, reaction := range r.Reactions {
+		if err := reaction.validate(r.Types); err != nil {
+			return fmt.Errorf("reaction %v: %v", reaction, err)
+		}
+	}
+	for _, f := range r.Fields {
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	for _, rule := range r.BondRules {
+		if err := rule.validate(r.Types); err != nil {
+			return fmt.Errorf("bond rule %v: %v", rule, err)
+		}
+	}
+
+	if err := w.SetTypes(r.Types); err != nil {
+		return err
+	}
+	for i := range r.Attraction {
+		copy(attract.AttractionMatrix[i], r.Attraction[i])
+	}
+	for i := range r.Radius {
+		copy(attract.RadiusMatrix[i], r.Radius[i])
+	}
+	copy(w.Properties, r.Properties)
+	w.Timeline = r.Timeline
+	w.Reactions = r.Reactions
+	w.Fields = r.Fields
+	w.BondRules = r.BondRules
+	// rulesets from before the curve kernel have no curve
+	if len(r.Curve.Points) > 0 {
+		attract.ForceCurve = r.Curve
+		attract.ForceCurve.Sort()
+	}
+	for i, row := range r.Kernels {
+		for j, name := range row {
+			if params := r.params(i, j); params != nil || attract.Kernels[name].Params == 0 {
+				w.setKernel(i, j, name, params)
+			} else {
+				// rulesets from before kernel parameters were saved
+				w.SetKernel(i, j, name)
+			}
+		}
+	}
+	return nil
+}
+
+// params returns the saved parameters of the kernel of pair i, j, or nil.
+func (r *Ruleset) params(i, j int) []float64 {
+	if i >= len(r.KernelParams) || j >= len(r.KernelParams[i]) {
+		return nil
+	}
+	return r.KernelParams[i][j]
+}
+
+// checkSize makes sure no table in the ruleset has more types than it says.
+func (r *Ruleset) checkSize() error {
+	n := len(r.Properties)
+	for _, table := range [][][]float64{r.Attraction, r.Radius} {
+		n = maxInt(n, len(table))
+		for _, row := range table {
+			n = maxInt(n, len(row))
+		}
+	}
+	n = maxInt(n, len(r.Kernels))
+	for _, row := range r.Kernels {
+		n = maxInt(n, len(row))
+	}
+	n = maxInt(n, len(r.KernelParams))
+	for _, row := range r.KernelParams {
+		n = maxInt(n, len(row))
+	}
+	if n > r.Types {
+		return fmt.Errorf("ruleset has %d types but tables for %d", r.Types, n)
+	}
+	return nil
+}
+
+func maxInt(a, b int) int {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+// SaveRuleset writes the rules of the world to a JSON file.
+func (w *World) SaveRuleset(path string) error {
+	data, err := json.MarshalIndent(w.Ruleset(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadRuleset reads rules written by SaveRuleset and applies them.
+func (w *World) LoadRuleset(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var r Ruleset
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+	if r.Types < 1 || r.Types > settings.MaxTypes {
+		return fmt.Errorf("ruleset has %d types, want 1 to %d", r.Types, settings.MaxTypes)
+	}
+	return w.ApplyRuleset(r)
+}
@@ -0,0 +1,104 @@
+package sim
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Snapshot is the complete state of a world: its rules, its particles, the
+// bonds between them, the obstacles they move between and the state of its
+// random numbers, so a restored world goes on exactly as the original did.
+type Snapshot struct {
+	Ruleset   Ruleset
+	Particles []Particle
+	Bonds     []Bond
+	Obstacles []Obstacle
+	Mask      *Mask `json:",omitempty"`
+	Ticks     int
+	Rand      [4]uint64
+}
+
+// Snapshot captures the current state of the world.
+func (w *World) Snapshot() Snapshot {
+	return Snapshot{
+		Ruleset:   w.Ruleset(),
+		Particles: append([]Particle(nil), w.Particles...),
+		Bonds:     append([]Bond(nil), w.Bonds...),
+		Obstacles: append([]Obstacle(nil), w.Obstacles...),
+		Mask:      w.Mask,
+		Ticks:     w.Ticks,
+		Rand:      w.source.state,
+	}
+}
+
+// Restore puts the world back in the state of a snapshot.
+func (w *World) Restore(s Snapshot) error {
+	for _, o := range s.Obstacles {
+		if err := o.validate(); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.Particles {
+		if int(p.Type) >= s.Ruleset.Types {
+			return fmt.Errorf("particle of type %d in a snapshot with %d types", p.Type, s.Ruleset.Types)
+		}
+	}
+	for _, b := range s.Bonds {
+		if b.I < 0 || b.J < 0 || b.I >= len(s.Particles) || b.J >= len(s.Particles) || b.I == b.J {
+			return fmt.Errorf("bond between particles %d and %d of %d", b.I, b.J, len(s.Particles))
+		}
+	}
+	if m := s.Mask; m != nil && (m.Width < 1 || m.Height < 1 || len(m.Bits) != (m.Width*m.Height+7)/8) {
+		return fmt.Errorf("mask of %dx%d with %d bytes", m.Width, m.Height, len(m.Bits))
+	}
+	if err := w.ApplyRuleset(s.Ruleset); err != nil {
+		return err
+	}
+	w.Particles = append(w.Particles[:0], s.Particles...)
+	w.Bonds = append([]Bond(nil), s.Bonds...)
+	w.Obstacles = append([]Obstacle(nil), s.Obstacles...)
+	w.Mask = s.Mask
+	w.Ticks = s.Ticks
+	// snapshots from before the state was saved leave it as it is
+	if s.Rand != [4]uint64{} {
+		w.source.state = s.Rand
+	}
+	return nil
+}
+
+// SaveSnapshot writes the state of the world to a gzipped JSON file.
+func (w *World) SaveSnapshot(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	z := gzip.NewWriter(f)
+	if err := json.NewEncoder(z).Encode(w.Snapshot()); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// LoadSnapshot reads a state written by SaveSnapshot and restores it.
+func (w *World) LoadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	var s Snapshot
+	if err := json.NewDecoder(z).Decode(&s); err != nil {
+		return err
+	}
+	return w.Restore(s)
+}
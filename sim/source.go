@@ -0,0 +1,46 @@
+package sim
+
+import "math/bits"
+
+// source is the xoshiro256** generator behind World.Rand. Unlike the source
+// of math/rand its state can be read and set, so a snapshot carries on with
+// the same random numbers the world it was taken from would have drawn.
+type source struct {
+	state [4]uint64
+}
+
+func newSource(seed int64) *source {
+	src := &source{}
+	src.Seed(seed)
+	return src
+}
+
+// Seed fills the state from seed with splitmix64, which never gives the
+// all-zero state xoshiro cannot leave.
+func (src *source) Seed(seed int64) {
+	x := uint64(seed)
+	for i := range src.state {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		src.state[i] = z ^ z>>31
+	}
+}
+
+func (src *source) Uint64() uint64 {
+	s := &src.state
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+func (src *source) Int63() int64 {
+	return int64(src.Uint64() >> 1)
+}
@@ -0,0 +1,87 @@
+package sim
+
+import (
+	"fmt"
+	"life/settings"
+	"log"
+	"math"
+)
+
+// ThermostatNames lists the thermostats in a stable order, e.g. for cycling
+// through them.
+var ThermostatNames = []string{"none", "berendsen", "langevin"}
+
+// CheckThermostat returns an error if settings.Temperature or
+// settings.ThermostatTau is out of range.
+func CheckThermostat() error {
+	if !(settings.Temperature >= 0) || math.IsInf(settings.Temperature, 1) {
+		return fmt.Errorf("temperature %g, want 0 or more", settings.Temperature)
+	}
+	if !(settings.ThermostatTau > 0) || math.IsInf(settings.ThermostatTau, 1) {
+		return fmt.Errorf("thermostat tau %g, want more than 0", settings.ThermostatTau)
+	}
+	return nil
+}
+
+// KineticTemperature is the mean kinetic energy of the particles, which in
+// two dimensions is the temperature they would have in equilibrium.
+func (w *World) KineticTemperature() float64 {
+	if len(w.Particles) == 0 {
+		return 0
+	}
+	sum := 0.
+	for _, p := range w.Particles {
+		m := w.Properties[p.Type].Mass
+		sum += m * (p.Velocity[0]*p.Velocity[0] + p.Velocity[1]*p.Velocity[1]) / 2
+	}
+	return sum / float64(len(w.Particles))
+}
+
+// thermostat heats the particles towards settings.Temperature over dt. Without
+// a thermostat it only adds Gaussian noise to the velocities, which friction
+// works against. Berendsen instead rescales every velocity so the kinetic
+// temperature relaxes towards the target over settings.ThermostatTau.
+// Langevin gives each particle its own friction and matching noise, which
+// samples the target temperature exactly.
+//
+// Noise is drawn from w.Rand in particle order, so runs stay reproducible.
+func (w *World) thermostat(dt float64) {
+	t := settings.Temperature
+	switch settings.Thermostat {
+	case "none":
+		if t > 0 {
+			w.noise(dt)
+		}
+	case "berendsen":
+		current := w.KineticTemperature()
+		if current == 0 {
+			return
+		}
+		scale := math.Sqrt(math.Max(0, 1+dt/settings.ThermostatTau*(t/current-1)))
+		for i := range w.Particles {
+			w.Particles[i].Velocity[0] *= scale
+			w.Particles[i].Velocity[1] *= scale
+		}
+	case "langevin":
+		decay := math.Exp(-dt / settings.ThermostatTau)
+		for i := range w.Particles {
+			p := &w.Particles[i]
+			sigma := math.Sqrt(t / w.Properties[p.Type].Mass * (1 - decay*decay))
+			p.Velocity[0] = p.Velocity[0]*decay + sigma*w.Rand.NormFloat64()
+			p.Velocity[1] = p.Velocity[1]*decay + sigma*w.Rand.NormFloat64()
+		}
+	default:
+		log.Fatal("Unknown thermostat.")
+	}
+}
+
+// noise kicks every particle by Gaussian noise with a variance of
+// settings.Temperature over its mass per unit of time.
+func (w *World) noise(dt float64) {
+	for i := range w.Particles {
+		p := &w.Particles[i]
+		sigma := math.Sqrt(settings.Temperature * dt / w.Properties[p.Type].Mass)
+		p.Velocity[0] += sigma * w.Rand.NormFloat64()
+		p.Velocity[1] += sigma * w.Rand.NormFloat64()
+	}
+}
@@ -0,0 +1,138 @@
+package sim
+
+import (
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"log"
+)
+
+// Keyframe is an attraction matrix the timeline passes through.
+type Keyframe struct {
+	Attraction [][]float64
+	// Ticks is the number of steps taken to morph from this keyframe to the
+	// next.
+	Ticks int
+}
+
+// Timeline morphs attract.AttractionMatrix through its keyframes, moving on by
+// one tick every step while it plays.
+type Timeline struct {
+	Keyframes []Keyframe
+	Easing    string // "linear" or "ease"
+	// Loop morphs from the last keyframe back to the first and starts over,
+	// rather than stopping at the last.
+	Loop    bool
+	Playing bool
+	// Tick is the number of steps since the first keyframe.
+	Tick int
+}
+
+// EasingNames lists the ways of moving between keyframes.
+var EasingNames = []string{"linear", "ease"}
+
+// AddKeyframe adds the current attraction matrix of the active types to the
+// end of the timeline, taking ticks steps to morph away from it.
+func (tl *Timeline) AddKeyframe(ticks int) {
+	k := Keyframe{Attraction: make([][]float64, settings.Types), Ticks: ticks}
+	for i := range k.Attraction {
+		k.Attraction[i] = append([]float64(nil), attract.AttractionMatrix[i][:settings.Types]...)
+	}
+	tl.Keyframes = append(tl.Keyframes, k)
+}
+
+// validate checks a timeline read from a file.
+func (tl *Timeline) validate() error {
+	if tl.Easing != "" && tl.Easing != "linear" && tl.Easing != "ease" {
+		return fmt.Errorf("unknown easing %q", tl.Easing)
+	}
+	for i, k := range tl.Keyframes {
+		if k.Ticks < 1 {
+			return fmt.Errorf("keyframe %d lasts %d ticks, want at least 1", i, k.Ticks)
+		}
+		if len(k.Attraction) > settings.MaxTypes {
+			return fmt.Errorf("keyframe %d has %d types, want at most %d", i, len(k.Attraction), settings.MaxTypes)
+		}
+		for _, row := range k.Attraction {
+			if len(row) > settings.MaxTypes {
+				return fmt.Errorf("keyframe %d has %d types, want at most %d", i, len(row), settings.MaxTypes)
+			}
+		}
+	}
+	return nil
+}
+
+// segments is the number of keyframes that are morphed away from.
+func (tl *Timeline) segments() int {
+	if tl.Loop {
+		return len(tl.Keyframes)
+	}
+	return len(tl.Keyframes) - 1
+}
+
+// Length is the number of ticks from the first keyframe to the end.
+func (tl *Timeline) Length() int {
+	n := 0
+	for i := 0; i < tl.segments(); i++ {
+		n += tl.Keyframes[i].Ticks
+	}
+	return n
+}
+
+// advance moves a playing timeline on by a tick and applies it.
+func (tl *Timeline) advance() {
+	if !tl.Playing || len(tl.Keyframes) < 2 {
+		return
+	}
+	tl.Tick++
+	if length := tl.Length(); tl.Tick >= length {
+		if tl.Loop && length > 0 {
+			tl.Tick %= length
+		} else {
+			tl.Tick = length
+			tl.Playing = false
+		}
+	}
+	tl.Apply()
+}
+
+// Apply writes the matrix at the current tick to attract.AttractionMatrix.
+func (tl *Timeline) Apply() {
+	if len(tl.Keyframes) == 0 {
+		return
+	}
+	tick := tl.Tick
+	for i := 0; i < tl.segments(); i++ {
+		k := tl.Keyframes[i]
+		if tick < k.Ticks {
+			next := tl.Keyframes[(i+1)%len(tl.Keyframes)]
+			blend(k.Attraction, next.Attraction, tl.ease(float64(tick)/float64(k.Ticks)))
+			return
+		}
+		tick -= k.Ticks
+	}
+	last := tl.Keyframes[len(tl.Keyframes)-1].Attraction
+	blend(last, last, 0)
+}
+
+func (tl *Timeline) ease(s float64) float64 {
+	switch tl.Easing {
+	case "linear", "":
+		return s
+	case "ease":
+		// smoothstep, so the rules linger at each keyframe
+		return s * s * (3 - 2*s)
+	}
+	log.Fatal("Unknown easing.")
+	return 0
+}
+
+// blend sets the attraction matrix to a, moved by s of the way towards b.
+// Keyframes taken with different numbers of types only share their overlap.
+func blend(a, b [][]float64, s float64) {
+	for i := 0; i < len(a) && i < len(b) && i < len(attract.AttractionMatrix); i++ {
+		for j := 0; j < len(a[i]) && j < len(b[i]) && j < len(attract.AttractionMatrix[i]); j++ {
+			attract.AttractionMatrix[i][j] = a[i][j] + s*(b[i][j]-a[i][j])
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package sim
+
+import (
+	"life/settings"
+	"math"
+)
+
+// The tools act on every particle within radius of a point, measured in the
+// world's metric and across its edges like the pair forces. They are meant to
+// be applied once per frame while the mouse is held.
+
+// around calls fn for every particle within radius of x, y, with the
+// displacement from the point to the particle.
+func (w *World) around(x, y, radius float64, fn func(p *Particle, dx, dy, d float64)) {
+	metric, boundary := currentMetric(), currentBoundary()
+	for i := range w.Particles {
+		p := &w.Particles[i]
+		dx, dy, d := boundary.displacement(x, y, p.X, p.Y, metric)
+		if d <= radius {
+			fn(p, dx, dy, d)
+		}
+	}
+}
+
+// Push gives the particles within radius of x, y an impulse towards the point,
+// or away from it when strength is negative. It is strongest at the point and
+// fades to nothing at the radius.
+func (w *World) Push(x, y, radius, strength float64) {
+	w.around(x, y, radius, func(p *Particle, dx, dy, d float64) {
+		if d == 0 {
+			return
+		}
+		v := strength * (1 - d/radius)
+		p.Velocity[0] -= dx / d * v
+		p.Velocity[1] -= dy / d * v
+	})
+}
+
+// Swirl gives the particles within radius of x, y an impulse around the point,
+// clockwise on screen for a positive strength.
+func (w *World) Swirl(x, y, radius, strength float64) {
+	w.around(x, y, radius, func(p *Particle, dx, dy, d float64) {
+		if d == 0 {
+			return
+		}
+		v := strength * (1 - d/radius)
+		p.Velocity[0] -= dy / d * v
+		p.Velocity[1] += dx / d * v
+	})
+}
+
+// Spawn adds n particles of type t at random within radius of x, y, as long as
+// there is room under settings.MaxParticles.
+func (w *World) Spawn(x, y, radius float64, t, n int) {
+	for ; n > 0 && len(w.Particles) < settings.MaxParticles; n-- {
+		a, r := 2*math.Pi*w.Rand.Float64(), radius*math.Sqrt(w.Rand.Float64())
+		p := Particle{X: x + r*math.Cos(a), Y: y + r*math.Sin(a), Type: uint16(t)}
+		if currentBoundary().confine(&p) {
+			w.Particles = append(w.Particles, p)
+		}
+	}
+}
+
+// Erase removes the particles within radius of x, y.
+func (w *World) Erase(x, y, radius float64) {
+	metric, boundary := currentMetric(), currentBoundary()
+	alive := make([]bool, len(w.Particles))
+	kept := w.Particles[:0]
+	for i, p := range w.Particles {
+		if _, _, d := boundary.displacement(x, y, p.X, p.Y, metric); d > radius {
+			alive[i] = true
+			kept = append(kept, p)
+		}
+	}
+	w.Particles = kept
+	w.dropBonds(alive)
+}
+
+// Drag moves the particles within radius of fromX, fromY along with the cursor
+// to toX, toY and stops them, so a group can be picked up and carried. Any
+// particles the group passes over are picked up too.
+func (w *World) Drag(fromX, fromY, toX, toY, radius float64) {
+	// a group dragged into an absorbing wall stops there rather than vanishing
+	boundary := currentBoundary()
+	boundary.Absorb = false
+	w.around(fromX, fromY, radius, func(p *Particle, dx, dy, d float64) {
+		p.X += toX - fromX
+		p.Y += toY - fromY
+		p.Velocity = [2]float64{}
+		boundary.confine(p)
+	})
+}
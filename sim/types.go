@@ -0,0 +1,99 @@
+package sim
+
+import (
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"math"
+)
+
+// TypeProperties are the physical properties shared by every particle of a
+// type.
+type TypeProperties struct {
+	// Mass divides the acceleration from pair forces.
+	Mass float64
+	// Friction is the fraction of velocity kept per unit of time.
+	Friction float64
+	// MaxSpeed caps the velocity. 0 leaves it uncapped.
+	MaxSpeed float64
+	// Size is the side length the type is drawn with, in pixels.
+	Size int
+}
+
+// DefaultProperties returns the properties a new type starts with, taken from
+// the global settings.
+func DefaultProperties() TypeProperties {
+	return TypeProperties{
+		Mass:     1,
+		Friction: settings.Friction,
+		Size:     settings.ParticleSize,
+	}
+}
+
+// validate checks the properties are in range.
+func (p TypeProperties) validate() error {
+	if !(p.Mass > 0) || math.IsInf(p.Mass, 1) {
+		return fmt.Errorf("mass %g, want more than 0", p.Mass)
+	}
+	if !(p.Friction >= 0 && p.Friction <= 1) {
+		return fmt.Errorf("friction %g, want 0 to 1", p.Friction)
+	}
+	if !(p.MaxSpeed >= 0) || math.IsInf(p.MaxSpeed, 1) {
+		return fmt.Errorf("max speed %g, want 0 or more", p.MaxSpeed)
+	}
+	if p.Size < 1 {
+		return fmt.Errorf("size %d, want at least 1", p.Size)
+	}
+	return nil
+}
+
+// SetTypes makes n types active. The tables of the world and the matrices grow
+// as needed, with the new pairs using defaultKernel, but never shrink, so
+// types that are removed and added again keep their rules.
+func (w *World) SetTypes(n int) error {
+	if n < 1 || n > settings.MaxTypes {
+		return fmt.Errorf("%d types, want 1 to %d", n, settings.MaxTypes)
+	}
+	attract.Resize(n, w.Rand)
+	old := len(w.Kernels)
+	for t := 0; t < n; t++ {
+		if t >= old {
+			w.Properties = append(w.Properties, DefaultProperties())
+			w.Kernels = append(w.Kernels, nil)
+			w.Params = append(w.Params, nil)
+			w.Attractors = append(w.Attractors, nil)
+		}
+		for ot := len(w.Kernels[t]); ot < n; ot++ {
+			w.Kernels[t] = append(w.Kernels[t], "")
+			w.Params[t] = append(w.Params[t], nil)
+			w.Attractors[t] = append(w.Attractors[t], nil)
+			w.SetKernel(t, ot, defaultKernel())
+		}
+	}
+	settings.Types = n
+	return nil
+}
+
+// defaultKernel is settings.Kernel if it names a single kernel, or "default".
+func defaultKernel() string {
+	if _, ok := attract.Kernels[settings.Kernel]; ok {
+		return settings.Kernel
+	}
+	return "default"
+}
+
+// damp applies friction over dt and the speed cap of the particle's type.
+func (w *World) damp(p *Particle, dt float64) {
+	prop := w.Properties[p.Type]
+	friction := math.Pow(prop.Friction, dt)
+	p.Velocity[0] *= friction
+	p.Velocity[1] *= friction
+
+	if prop.MaxSpeed > 0 {
+		speed := math.Hypot(p.Velocity[0], p.Velocity[1])
+		if speed > prop.MaxSpeed {
+			p.Velocity[0] *= prop.MaxSpeed / speed
+			p.Velocity[1] *= prop.MaxSpeed / speed
+		}
+	}
+}
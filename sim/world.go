@@ -0,0 +1,347 @@
+// Package sim holds the particle physics, independent of any rendering, so
+// that a world can be stepped without opening a window.
+package sim
+
+import (
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// World is a complete simulation: the particles, the attraction function and
+// physical properties of each type. The interaction matrices live in the
+// attract package and the remaining parameters in the settings package.
+//
+// Every random decision goes through Rand, so two worlds created with the same
+// seed and settings follow identical trajectories.
+type World struct {
+	Particles []Particle
+	// Attractors holds the kernel acting between each pair of types, indexed
+	// by the type feeling the force and then the type exerting it.
+	Attractors [][]attract.AttractionFunction
+	// Kernels names the kernel in attract.Kernels used by each pair, and
+	// Params holds the parameters of those that have any.
+	Kernels    [][]string
+	Params     [][][]float64
+	Properties []TypeProperties
+	Seed       int64
+	Rand       *rand.Rand
+	source     *source
+	// Ticks counts the steps taken.
+	Ticks int
+	// Timeline morphs the attraction matrix as the world steps.
+	Timeline Timeline
+	// Reactions change the types of particles as the world steps.
+	Reactions []Reaction
+	// Fields accelerate every particle on top of the pair forces.
+	Fields []Field
+	// BondRules form the Bonds, springs between pairs of particles.
+	BondRules []BondRule
+	Bonds     []Bond
+	// Obstacles and Mask are static walls the particles bounce off.
+	Obstacles []Obstacle
+	Mask      *Mask
+
+	back    []Particle
+	alive   []bool
+	acc     [][2]float64
+	stage   []Particle
+	sum     [][4]float64
+	pending float64
+	grid    *Grid
+	// reactGrid and counts are scratch space for the reactions
+	reactGrid *Grid
+	counts    []int32
+	metric    Metric
+	boundary  Boundary
+	// links indexes Bonds by particle, and bondGrid and reach are scratch
+	// space for forming them
+	links    [][]int
+	bondGrid *Grid
+	reach    [][][2]int
+	// collideGrid finds the particles that overlap
+	collideGrid *Grid
+}
+
+// NewWorld creates a world, randomizes the interaction matrices and places
+// the particles. A seed of 0 picks one from the clock.
+func NewWorld(seed int64) *World {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	src := newSource(seed)
+	w := &World{
+		Seed:   seed,
+		Rand:   rand.New(src),
+		source: src,
+	}
+	if err := w.SetTypes(settings.Types); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.SetKernels(settings.Kernel); err != nil {
+		log.Fatal(err)
+	}
+	if err := attract.RandomizeAttractionMatrix(w.Rand); err != nil {
+		log.Fatal(err)
+	}
+	attract.RandomizeRadiusMatrix(w.Rand)
+	w.Setup()
+	return w
+}
+
+// SetKernel makes the force type t feels from type ot use the named kernel
+// from attract.Kernels, drawing new parameters for it.
+func (w *World) SetKernel(t, ot int, name string) error {
+	kernel, ok := attract.Kernels[name]
+	if !ok {
+		return fmt.Errorf("unknown kernel %q", name)
+	}
+	var params []float64
+	if kernel.Draw != nil {
+		params = kernel.Draw(w.Rand)
+	}
+	return w.setKernel(t, ot, name, params)
+}
+
+// setKernel is SetKernel with the parameters given, e.g. from a ruleset.
+func (w *World) setKernel(t, ot int, name string, params []float64) error {
+	kernel, ok := attract.Kernels[name]
+	if !ok {
+		return fmt.Errorf("unknown kernel %q", name)
+	}
+	if len(params) != kernel.Params {
+		return fmt.Errorf("kernel %q takes %d parameters, got %d", name, kernel.Params, len(params))
+	}
+	w.Kernels[t][ot] = name
+	w.Params[t][ot] = params
+	w.Attractors[t][ot] = kernel.New(params)
+	return nil
+}
+
+// RebuildKernel recreates every pair using the named kernel, e.g. after its
+// expression was replaced.
+func (w *World) RebuildKernel(name string) {
+	for t, row := range w.Kernels {
+		for ot, k := range row {
+			if k == name {
+				w.SetKernel(t, ot, name)
+			}
+		}
+	}
+}
+
+// SetTypeKernel makes every force type t feels use the named kernel.
+func (w *World) SetTypeKernel(t int, name string) error {
+	for ot := range w.Kernels[t] {
+		if err := w.SetKernel(t, ot, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetKernels sets kernels from a comma separated list of names. A single name
+// is used for every pair; otherwise the names go to every force felt by types
+// 0, 1, 2, ... and the remaining types are left as they were.
+func (w *World) SetKernels(list string) error {
+	names := strings.Split(list, ",")
+	if len(names) == 1 {
+		for i := 1; i < len(w.Kernels); i++ {
+			names = append(names, names[0])
+		}
+	}
+	if len(names) > len(w.Kernels) {
+		return fmt.Errorf("%d kernels given for %d types", len(names), len(w.Kernels))
+	}
+	for i, name := range names {
+		if err := w.SetTypeKernel(i, strings.TrimSpace(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Setup discards all particles and places settings.NParticles new ones
+// according to settings.Arrangement, leaving out any that would be inside an
+// obstacle.
+func (w *World) Setup() {
+	w.Particles = make([]Particle, 0, settings.NParticles)
+	w.Bonds = nil
+	switch settings.Arrangement {
+	case "random":
+		for i := 0; i < settings.NParticles; i++ {
+			w.Particles = append(w.Particles, Particle{
+				X:    w.Rand.Float64() * settings.Width,
+				Y:    w.Rand.Float64() * settings.Height,
+				Type: uint16(w.Rand.Intn(settings.Types)),
+			})
+		}
+	case "circle":
+		for i := 0; i < settings.NParticles; i++ {
+			angle := float64(i) * 2 * math.Pi / float64(settings.NParticles)
+			w.Particles = append(w.Particles, Particle{
+				X:    settings.Width/2 + math.Cos(angle)*settings.Width/2,
+				Y:    settings.Height/2 + math.Sin(angle)*settings.Height/2,
+				Type: uint16(w.Rand.Intn(settings.Types)),
+			})
+		}
+	case "f_circle": // filled circle
+		for i := 0; i < settings.NParticles; i++ {
+			angle := float64(i) * 2 * math.Pi / float64(settings.NParticles)
+			w.Particles = append(w.Particles, Particle{
+				X:    settings.Width/2 + math.Cos(angle)*settings.Width/2 + 20*(w.Rand.Float64()-.5),
+				Y:    settings.Height/2 + math.Sin(angle)*settings.Height/2 + 20*(w.Rand.Float64()-.5),
+				Type: uint16(w.Rand.Intn(settings.Types)),
+			})
+		}
+	case "concentric":
+		for ring := 0; ring < settings.Types; ring++ {
+			for i := 0; i < settings.NParticles/settings.Types; i++ {
+				angle := float64(i) * 2 * math.Pi / float64(settings.NParticles/settings.Types)
+				w.Particles = append(w.Particles, Particle{
+					X:    float64(settings.Width/2+math.Cos(angle)*settings.Width/2*float64(ring)/float64(settings.Types)) + w.Rand.Float64() - .5,
+					Y:    float64(settings.Height/2+math.Sin(angle)*settings.Height/2*float64(ring)/float64(settings.Types)) + w.Rand.Float64() - .5,
+					Type: uint16(ring),
+				})
+			}
+		}
+	case "line":
+		for i := 0; i < settings.NParticles; i++ {
+			w.Particles = append(w.Particles, Particle{
+				X:    float64(i) * settings.Width / float64(settings.NParticles),
+				Y:    settings.Height/2 + w.Rand.Float64() - .5,
+				Type: uint16(w.Rand.Intn(settings.Types)),
+			})
+		}
+	case "grid":
+		for x := 0; x < int(math.Sqrt(float64(settings.NParticles))); x++ {
+			for y := 0; y < int(math.Sqrt(float64(settings.NParticles))); y++ {
+				w.Particles = append(w.Particles, Particle{
+					X:    float64(x)*settings.Width/math.Sqrt(float64(settings.NParticles)) + w.Rand.Float64() - .5,
+					Y:    float64(y)*settings.Height/math.Sqrt(float64(settings.NParticles)) + w.Rand.Float64() - .5,
+					Type: uint16(w.Rand.Intn(settings.Types)),
+				})
+			}
+		}
+	case "row":
+		for t := 0; t < settings.Types; t++ {
+			for i := 0; i < settings.NParticles/settings.Types; i++ {
+				w.Particles = append(w.Particles, Particle{
+					X:    (settings.Width/float64(settings.Types))*(float64(t)+w.Rand.Float64()) - 20,
+					Y:    settings.Height/2 + 20*(w.Rand.Float64()-.5),
+					Type: uint16(t),
+				})
+			}
+		}
+	case "point":
+		for i := 0; i < settings.NParticles; i++ {
+			w.Particles = append(w.Particles, Particle{
+				X:    settings.Width/2 + w.Rand.Float64() - .5,
+				Y:    settings.Height/2 + w.Rand.Float64() - .5,
+				Type: uint16(w.Rand.Intn(settings.Types)),
+			})
+		}
+	default:
+		log.Fatal("Unknown arrangement.")
+	}
+
+	// none start inside a wall
+	kept := w.Particles[:0]
+	for _, p := range w.Particles {
+		if !w.Blocked(p.X, p.Y) {
+			kept = append(kept, p)
+		}
+	}
+	w.Particles = kept
+}
+
+// Step advances the world by settings.DT using settings.Integrator. Forces are
+// computed from the current particles, which are only read, and the moved
+// particles are written to a second buffer that replaces them once every
+// worker has finished.
+func (w *World) Step() {
+	w.Ticks++
+	w.Timeline.advance()
+	w.metric = currentMetric()
+	w.boundary = currentBoundary()
+
+	n := len(w.Particles)
+	// back is swapped with Particles, so it can outgrow the others
+	if cap(w.back) < n {
+		w.back = make([]Particle, n)
+	}
+	if cap(w.alive) < n {
+		w.alive = make([]bool, n)
+	}
+	if cap(w.acc) < n {
+		w.acc = make([][2]float64, n)
+	}
+	w.back, w.alive, w.acc = w.back[:n], w.alive[:n], w.acc[:n]
+	w.link()
+
+	switch settings.Integrator {
+	case "euler":
+		w.euler(settings.DT)
+	case "verlet":
+		w.verlet(settings.DT)
+	case "rk4":
+		w.rk4(settings.DT)
+	default:
+		log.Fatal("Unknown integrator.")
+	}
+
+	workers.run(n, func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			w.damp(&w.back[i], settings.DT)
+			w.alive[i] = w.boundary.confine(&w.back[i])
+			if w.alive[i] {
+				w.collide(w.Particles[i], &w.back[i])
+			}
+		}
+	})
+	if w.boundary.Absorb {
+		w.dropAbsorbed()
+	}
+	w.Particles, w.back = w.back, w.Particles
+	w.collideParticles()
+	w.thermostat(settings.DT)
+	w.react()
+	w.bond()
+}
+
+// dropAbsorbed removes the particles in back that alive marks as absorbed,
+// along with their bonds.
+func (w *World) dropAbsorbed() {
+	w.dropBonds(w.alive[:len(w.back)])
+	kept := w.back[:0]
+	for i, p := range w.back {
+		if w.alive[i] {
+			kept = append(kept, p)
+		}
+	}
+	w.back = kept
+}
+
+// Advance runs as many steps as fit in elapsed seconds of real time at
+// settings.StepRate, carrying the remainder over to the next call, so the
+// simulation runs at the same pace whatever the frame rate. It never runs more
+// than settings.MaxSubsteps steps, so a slow machine falls behind rather than
+// stalling. It returns the number of steps taken.
+func (w *World) Advance(elapsed float64) int {
+	w.pending += elapsed * settings.StepRate
+	steps := 0
+	for w.pending >= 1 && steps < settings.MaxSubsteps {
+		w.Step()
+		w.pending--
+		steps++
+	}
+	if w.pending >= 1 {
+		w.pending = 0
+	}
+	return steps
+}
@@ -0,0 +1,256 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"life/attract"
+	"life/settings"
+	"math"
+	"testing"
+)
+
+// withWorkers runs the test on a pool of n workers, whatever GOMAXPROCS is,
+// so the parallel passes really do overlap under -race.
+func withWorkers(t *testing.T, n int) {
+	old := workers
+	workers = newPool(n)
+	t.Cleanup(func() { workers = old })
+}
+
+// withSetting sets *v for the rest of the test.
+func withSetting[T any](t testing.TB, v *T, to T) {
+	old := *v
+	*v = to
+	t.Cleanup(func() { *v = old })
+}
+
+// busyWorld returns a world with collisions, a reaction and a bond rule on,
+// so a step runs every pass there is.
+func busyWorld(t *testing.T, seed int64) *World {
+	withSetting(t, &settings.Collisions, "elastic")
+	w := NewWorld(seed)
+	r, err := ParseReaction("0+1>2 r=10 p=0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddReaction(r); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseBondRule("1-2 d=20 p=0.2 n=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddBondRule(b); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestStepWorkers(t *testing.T) {
+	withWorkers(t, 8)
+	for _, integrator := range IntegratorNames {
+		t.Run(integrator, func(t *testing.T) {
+			withSetting(t, &settings.Integrator, integrator)
+			w := busyWorld(t, 1)
+			for i := 0; i < 50; i++ {
+				w.Step()
+			}
+			for i, p := range w.Particles {
+				if math.IsNaN(p.X) || math.IsNaN(p.Y) {
+					t.Fatalf("particle %d is at %v, %v", i, p.X, p.Y)
+				}
+			}
+		})
+	}
+}
+
+func TestStepDeterministic(t *testing.T) {
+	withWorkers(t, 8)
+	run := func() []Particle {
+		w := busyWorld(t, 7)
+		for i := 0; i < 100; i++ {
+			w.Step()
+		}
+		return w.Particles
+	}
+	a, b := run(), run()
+	if len(a) != len(b) {
+		t.Fatalf("runs ended with %d and %d particles", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("particle %d differs between runs: %+v and %+v", i, a[i], b[i])
+		}
+	}
+}
+
+func BenchmarkStep(b *testing.B) {
+	for _, n := range []int{10000, 50000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			withSetting(b, &settings.NParticles, n)
+			w := NewWorld(1)
+			// the first step sets up the grid
+			w.Step()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w.Step()
+			}
+		})
+	}
+}
+
+func TestCollisionsAbsorb(t *testing.T) {
+	withSetting(t, &settings.Boundary, "absorb")
+	withSetting(t, &settings.NParticles, 1500)
+	w := busyWorld(t, 11)
+	for i := range w.Properties {
+		w.Properties[i].Size = 12
+	}
+	start := len(w.Particles)
+	for step := 0; step < 100; step++ {
+		w.Step()
+		for i, p := range w.Particles {
+			if p.X < 0 || p.X > settings.Width || p.Y < 0 || p.Y > settings.Height {
+				t.Fatalf("step %d: particle %d is outside the world at %v, %v", step, i, p.X, p.Y)
+			}
+		}
+		for _, b := range w.Bonds {
+			if b.I >= len(w.Particles) || b.J >= len(w.Particles) {
+				t.Fatalf("step %d: bond %d-%d past the %d particles", step, b.I, b.J, len(w.Particles))
+			}
+		}
+	}
+	if len(w.Particles) == start {
+		t.Fatal("no particle was absorbed")
+	}
+}
+
+func TestGridMatchesAllPairs(t *testing.T) {
+	for _, boundary := range BoundaryNames {
+		for metric := range Metrics {
+			t.Run(boundary+"/"+metric, func(t *testing.T) {
+				withSetting(t, &settings.Boundary, boundary)
+				withSetting(t, &settings.Metric, metric)
+				w := NewWorld(3)
+				w.metric, w.boundary = currentMetric(), currentBoundary()
+
+				if i, grid, all := compareSearches(t, w); i >= 0 {
+					t.Fatalf("particle %d: grid gives %v, all pairs %v", i, grid, all)
+				}
+			})
+		}
+	}
+}
+
+// compareSearches computes the accelerations in w with the grid and with all
+// pairs, and returns the first particle they differ for, or -1.
+func compareSearches(t *testing.T, w *World) (int, [2]float64, [2]float64) {
+	accelerations := func(search string) [][2]float64 {
+		withSetting(t, &settings.NeighbourSearch, search)
+		out := make([][2]float64, len(w.Particles))
+		w.accelerations(w.Particles, out)
+		return out
+	}
+	grid, all := accelerations("grid"), accelerations("all")
+	for i := range grid {
+		for k := range grid[i] {
+			if math.Abs(grid[i][k]-all[i][k]) > 1e-9*math.Max(1, math.Abs(all[i][k])) {
+				return i, grid[i], all[i]
+			}
+		}
+	}
+	return -1, [2]float64{}, [2]float64{}
+}
+
+// TestKernelsMatchAllPairs checks that every kernel is cut off at the radius
+// matrix, which the grid relies on to skip far particles.
+func TestKernelsMatchAllPairs(t *testing.T) {
+	withSetting(t, &settings.MinRadius, 20.)
+	withSetting(t, &settings.MaxRadius, 30.)
+	for _, kernel := range attract.KernelNames {
+		t.Run(kernel, func(t *testing.T) {
+			withSetting(t, &settings.Kernel, kernel)
+			w := NewWorld(5)
+			w.metric, w.boundary = currentMetric(), currentBoundary()
+			if i, grid, all := compareSearches(t, w); i >= 0 {
+				t.Fatalf("particle %d: grid gives %v, all pairs %v", i, grid, all)
+			}
+		})
+	}
+}
+
+func TestRulesetKeepsKernelParams(t *testing.T) {
+	withSetting(t, &settings.Kernel, "simple")
+	w := NewWorld(9)
+	w.metric, w.boundary = currentMetric(), currentBoundary()
+	want := make([][2]float64, len(w.Particles))
+	w.accelerations(w.Particles, want)
+
+	data, err := json.Marshal(w.Ruleset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// draw new parameters, which loading has to replace
+	if err := w.SetKernels("simple"); err != nil {
+		t.Fatal(err)
+	}
+	var r Ruleset
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.ApplyRuleset(r); err != nil {
+		t.Fatal(err)
+	}
+	got := make([][2]float64, len(w.Particles))
+	w.accelerations(w.Particles, got)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("particle %d: %v before saving, %v after loading", i, want[i], got[i])
+		}
+	}
+}
+
+func TestRewindReplays(t *testing.T) {
+	withSetting(t, &settings.Kernel, "simple")
+	withSetting(t, &settings.Thermostat, "langevin")
+	withSetting(t, &settings.Temperature, 1.)
+	w := busyWorld(t, 13)
+	for i := 0; i < 20; i++ {
+		w.Step()
+	}
+	r := NewRewind(1)
+	if err := r.Push(w); err != nil {
+		t.Fatal(err)
+	}
+	run := func() []Particle {
+		for i := 0; i < 30; i++ {
+			w.Step()
+		}
+		return append([]Particle(nil), w.Particles...)
+	}
+	a := run()
+	if err := r.Restore(w, 0); err != nil {
+		t.Fatal(err)
+	}
+	b := run()
+	if len(a) != len(b) {
+		t.Fatalf("runs ended with %d and %d particles", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("particle %d differs after rewinding: %+v and %+v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestObstacleStopsAtFirstEdge(t *testing.T) {
+	// a thin wall whose far edge is listed first
+	o := Obstacle{Kind: "polygon", Points: [][2]float64{{42, 100}, {42, 0}, {40, 0}, {40, 100}}}
+	old := Particle{X: 0, Y: 50, Velocity: [2]float64{100, 0}}
+	p := old
+	p.X = 100
+	o.collide(old, &p)
+	if p.X > 40 || o.Inside(p.X, p.Y) {
+		t.Fatalf("particle went through the wall to %v, %v", p.X, p.Y)
+	}
+}
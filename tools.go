@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"life/settings"
+	"life/sim"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// Tools lists the mouse tools in the order T cycles through them.
+var Tools = []string{"attract", "repel", "vortex", "spawn", "erase", "drag", "wall"}
+
+// toolColours tints the cursor overlay of each tool.
+var toolColours = map[string]color.RGBA{
+	"attract": {80, 200, 255, 255},
+	"repel":   {255, 120, 80, 255},
+	"vortex":  {200, 120, 255, 255},
+	"spawn":   {120, 255, 120, 255},
+	"erase":   {255, 60, 60, 255},
+	"drag":    {255, 255, 255, 255},
+	"wall":    {180, 180, 180, 255},
+}
+
+// The tools page picks the mouse tool and sets its radius and strength.
+var toolsPage = newPage("Tools", (*Game).drawToolPanel)
+
+func init() {
+	p := toolsPage
+	p.plusMinus("tool", pageY, func(g *Game) {
+		settings.Tool = cycleName(Tools, settings.Tool, 1)
+	}, func(g *Game) {
+		settings.Tool = cycleName(Tools, settings.Tool, -1)
+	})
+	p.plusMinus("radius", pageY+rowH, func(g *Game) {
+		settings.ToolRadius += 10
+	}, func(g *Game) {
+		settings.ToolRadius = math.Max(settings.ToolRadius-10, 10)
+	})
+	p.plusMinus("strength", pageY+2*rowH, func(g *Game) {
+		settings.ToolStrength += 0.5
+	}, func(g *Game) {
+		settings.ToolStrength = math.Max(settings.ToolStrength-0.5, 0.5)
+	})
+	p.button("Clear Walls", 8, pageY+5*rowH, 184, func(g *Game) {
+		g.world.Obstacles = nil
+		g.world.Mask = nil
+	})
+}
+
+// applyTool uses the mouse tool while the left button is held over the
+// world. Clicks on the panel are left to the UI.
+func (g *Game) applyTool() {
+	x, y := ebiten.CursorPosition()
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) || x >= settings.Width {
+		if settings.Tool == "wall" && g.dragging {
+			g.addWall(x, y)
+		}
+		g.dragging = false
+		return
+	}
+
+	fx, fy := float64(x), float64(y)
+	r, s := settings.ToolRadius, settings.ToolStrength
+	switch settings.Tool {
+	case "attract":
+		g.world.Push(fx, fy, r, s)
+	case "repel":
+		g.world.Push(fx, fy, r, -s)
+	case "vortex":
+		g.world.Swirl(fx, fy, r, s)
+	case "spawn":
+		g.world.Spawn(fx, fy, r, g.selectedType, int(math.Max(1, s)))
+	case "erase":
+		g.world.Erase(fx, fy, r)
+	case "drag":
+		if g.dragging {
+			g.world.Drag(g.dragFrom[0], g.dragFrom[1], fx, fy, r)
+		}
+		g.dragFrom, g.dragging = [2]float64{fx, fy}, true
+	case "wall":
+		// the wall is added when the button is released
+		if !g.dragging {
+			g.dragFrom, g.dragging = [2]float64{fx, fy}, true
+		}
+	default:
+		log.Fatal("Unknown tool.")
+	}
+}
+
+// drawCursor outlines the area the tool reaches, which is a circle only in
+// the Euclidean metric, and names the tool beside it.
+func (g *Game) drawCursor(screen *ebiten.Image) {
+	x, y := ebiten.CursorPosition()
+	if x < 0 || x >= settings.Width || y < 0 || y >= settings.Height {
+		return
+	}
+
+	c := toolColours[settings.Tool]
+	if settings.Tool == "wall" {
+		if g.dragging {
+			ebitenutil.DrawLine(screen, g.dragFrom[0], g.dragFrom[1], float64(x), float64(y), c)
+		}
+		ebitenutil.DebugPrintAt(screen, settings.Tool, x+8, y+8)
+		return
+	}
+	metric := sim.Metrics[settings.Metric]
+	const segments = 48
+	point := func(n int) (float64, float64) {
+		a := 2 * math.Pi * float64(n) / segments
+		dx, dy := math.Cos(a), math.Sin(a)
+		r := settings.ToolRadius / metric(dx, dy)
+		return float64(x) + r*dx, float64(y) + r*dy
+	}
+	lx, ly := point(0)
+	for n := 1; n <= segments; n++ {
+		px, py := point(n)
+		ebitenutil.DrawLine(screen, lx, ly, px, py, c)
+		lx, ly = px, py
+	}
+
+	if settings.Tool == "spawn" {
+		ebitenutil.DrawRect(screen, float64(x)-2, float64(y)-2, 5, 5, RGBColours[g.selectedType])
+	} else {
+		ebitenutil.DrawLine(screen, float64(x)-3, float64(y), float64(x)+4, float64(y), c)
+		ebitenutil.DrawLine(screen, float64(x), float64(y)-3, float64(x), float64(y)+4, c)
+	}
+	ebitenutil.DebugPrintAt(screen, settings.Tool, x+8, y+8)
+}
+
+// addWall adds a segment from where the wall tool was pressed to x, y, unless
+// it is too short to see.
+func (g *Game) addWall(x, y int) {
+	to := [2]float64{
+		math.Max(0, math.Min(float64(x), settings.Width)),
+		math.Max(0, math.Min(float64(y), settings.Height)),
+	}
+	if math.Hypot(to[0]-g.dragFrom[0], to[1]-g.dragFrom[1]) < 3 {
+		return
+	}
+	g.world.AddObstacle(sim.Obstacle{Kind: "segment", Points: [][2]float64{g.dragFrom, to}})
+}
+
+func (g *Game) drawToolPanel(screen *ebiten.Image) {
+	strength := fmt.Sprintf("%.1f", settings.ToolStrength)
+	if settings.Tool == "spawn" {
+		strength = fmt.Sprintf("%d/f", int(math.Max(1, settings.ToolStrength)))
+	}
+	ebitenutil.DebugPrintAt(screen, "Tool: "+settings.Tool, settings.Width+8, pageY+2)
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Radius: %.0f", settings.ToolRadius), settings.Width+8, pageY+rowH+2)
+	ebitenutil.DebugPrintAt(screen, "Strength: "+strength, settings.Width+8, pageY+2*rowH+2)
+	ebitenutil.DebugPrintAt(screen, "Spawns the selected type,\nset on the Types page.", settings.Width+8, pageY+3*rowH+2)
+}